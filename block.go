@@ -0,0 +1,58 @@
+package fxp
+
+// DefaultMaxBlock is the largest block, in samples per channel, that
+// containers allocate space for if they aren't told otherwise with Prepare.
+// They will still process larger blocks, by splitting them up.
+const DefaultMaxBlock = 4096
+
+// Preparer is implemented by Tickers that need to know the largest block they
+// will be ticked with ahead of time, usually so they can allocate buffers
+// outside of the audio callback.
+type Preparer interface {
+	// Prepare is called before Tick with the largest number of samples
+	// per channel that Tick will be called with. Tickers that contain
+	// other Tickers should pass it on.
+	Prepare(maxBlock int)
+}
+
+// Prepare tells t, a Ticker or TickerOf any sample type, the largest block it
+// will be ticked with, if it wants to know. It should not be called
+// concurrently with Tick.
+func Prepare(t any, maxBlock int) {
+	if p, ok := t.(Preparer); ok {
+		p.Prepare(maxBlock)
+	}
+}
+
+// blockLen returns the number of samples per channel in a block, or 0 if
+// there are no channels.
+func blockLen[T Sample](inputs, outputs [][]T) int {
+	switch {
+	case len(outputs) != 0:
+		return len(outputs[0])
+	case len(inputs) != 0:
+		return len(inputs[0])
+	}
+	return 0
+}
+
+// split calls tick with successive pieces of inputs and outputs that are no
+// longer than maxBlock. ins and outs hold the pieces, so they need to be the
+// same length as inputs and outputs.
+func split[T Sample](maxBlock int, inputs, outputs, ins, outs [][]T, tick func(inputs, outputs [][]T)) {
+	n := blockLen(inputs, outputs)
+	if n <= maxBlock {
+		tick(inputs, outputs)
+		return
+	}
+	for start := 0; start < n; start += maxBlock {
+		end := min(n, start+maxBlock)
+		for i := range inputs {
+			ins[i] = inputs[i][start:end]
+		}
+		for i := range outputs {
+			outs[i] = outputs[i][start:end]
+		}
+		tick(ins, outs)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math"
@@ -18,6 +19,9 @@ import (
 	"github.com/pfcm/fxp/fix"
 	"github.com/pfcm/fxp/io"
 	"github.com/pfcm/fxp/osc"
+	"github.com/pfcm/fxp/patch"
+	// For its patch types.
+	_ "github.com/pfcm/fxp/spectral"
 )
 
 func s17s(fs ...float32) []fix.S17 {
@@ -29,48 +33,58 @@ func s17s(fs ...float32) []fix.S17 {
 }
 
 func main() {
-	g, ctx := errgroup.WithContext(interruptContext())
-	c := newCopier(1)
-	ch := fxp.Serially(
-		fxp.Concurrently(
-			// some oscillators
-			fxp.Serially(
-				fxp.Const{fix.S17(fix.U62FromFloat(float32(48)))},
-				osc.Sine(44100, 0),
-			),
-			// fxp.Serially(
-			// 	fxp.Const{fix.S17(fix.U62FromFloat(float32(55)))},
-			// 	osc.Sine(44100, 0),
-			// ),
-			// fxp.Serially(
-			// 	fxp.Const{fix.S17(fix.U62FromFloat(float32(60)))},
-			// 	osc.Sine(44100, 12),
-			// ),
-		),
-		fxp.Concurrently(
-			// generate an envelope
-			fxp.Serially(
-				fxp.Every(1, 1*time.Second, 44100),
-				env.AttackDecay(50*time.Millisecond, 200*time.Millisecond, 44100),
-			),
-			// mix the oscillators together
-			fxp.Sum(1),
-		),
-		// apply the envelope
-		fxp.Amp{},
-		// send it to a delay with some wet/dry mix
-		// TODO: feedback :(
-		fxp.Mult{2}, // broadcast
-		fxp.Concurrently(
-			delay.NewDelay(1700*time.Millisecond, 44100),
-			fxp.Noop{1},
-		),
-		fxp.Mixer{Gains: s17s(0.5, 0.5)},
-		c,
+	var (
+		patchFile   = flag.String("patch", "", "JSON patch to play instead of the built in one")
+		listDevices = flag.Bool("list_devices", false, "list the available audio devices and exit")
+		meter       = flag.Bool("meter", false, "count clipping in the patch, build with -tags fxpsat to count it inside each node too")
+		opts        = io.Options{Stats: &io.Stats{}}
 	)
+	flag.IntVar(&opts.SampleRate, "rate", io.DefaultSampleRate, "sample rate")
+	flag.IntVar(&opts.PeriodSize, "period", 0, "frames per device callback, 0 for the backend's default")
+	flag.StringVar(&opts.CaptureDevice, "capture", "", "name or ID of the capture device, if not the default")
+	flag.StringVar(&opts.PlaybackDevice, "playback", "", "name or ID of the playback device, if not the default")
+	flag.IntVar(&opts.BlockSize, "block", 0, "if set, process in blocks of this many frames off the audio thread")
+	flag.IntVar(&opts.Headroom, "headroom", 0, "blocks to buffer when -block is set, 0 for just enough to cover a period")
+	flag.Func("quantize", "how to quantize captured audio: truncate, round, dither or noiseshape", func(s string) error {
+		q, err := fix.ParseQuantization(s)
+		opts.Quantization = q
+		return err
+	})
+	flag.Parse()
+
+	if *listDevices {
+		capture, playback, err := io.Devices()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("capture:")
+		for _, d := range capture {
+			fmt.Println("\t", d)
+		}
+		fmt.Println("playback:")
+		for _, d := range playback {
+			fmt.Println("\t", d)
+		}
+		return
+	}
+
+	p := builtinPatch(float32(opts.SampleRate))
+	if *patchFile != "" {
+		var err error
+		p, err = patch.Load(*patchFile, patch.Env{SampleRate: float32(opts.SampleRate)})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *meter {
+		p.Meter()
+	}
+	c := newCopier(p.Outputs())
+	ch := fxp.Serially(p, c)
 
+	g, ctx := errgroup.WithContext(interruptContext())
 	g.Go(func() error {
-		return io.PlayWithDefaults(ctx, ch)
+		return io.Play(ctx, ch, opts)
 	})
 	g.Go(func() error {
 		t0 := time.Now()
@@ -85,7 +99,7 @@ func main() {
 				for _, f := range c.getRMS() {
 					s = append(s, fmt.Sprintf("%.2f", f))
 				}
-				fmt.Printf("\r%v: %v", time.Since(t0), s)
+				fmt.Printf("\r%v: %v %v%s", time.Since(t0).Truncate(time.Millisecond), s, opts.Stats.Read(), clipped(p))
 			}
 		}
 	})
@@ -93,6 +107,90 @@ func main() {
 	if err := g.Wait(); err != nil {
 		log.Fatal(err)
 	}
+	if *meter {
+		fmt.Println()
+		for _, s := range p.Saturation() {
+			fmt.Println(s)
+		}
+		if fix.Counting {
+			fmt.Printf("%d saturated ops\n", p.SaturatedOps())
+		}
+	}
+}
+
+// clipped summarises the clipping in a metered graph for the status line.
+func clipped(g *fxp.Graph) string {
+	sats := g.Saturation()
+	if sats == nil {
+		return ""
+	}
+	var total uint64
+	for _, s := range sats {
+		total += s.Total()
+	}
+	return fmt.Sprintf(", %d clipped", total)
+}
+
+// builtinPatch is a simple voice into a feedback delay, the same as
+// delay.json, at the given sample rate.
+func builtinPatch(rate float32) *fxp.Graph {
+	voice := fxp.Serially(
+		fxp.Concurrently(
+			// some oscillators
+			fxp.Serially(
+				fxp.Const{Val: fix.U62FromFloat(float32(48)).S17Bits()},
+				osc.Sine(rate, 0),
+			),
+			// fxp.Serially(
+			// 	fxp.Const{Val: fix.U62FromFloat(float32(55)).S17Bits()},
+			// 	osc.Sine(rate, 0),
+			// ),
+			// fxp.Serially(
+			// 	fxp.Const{Val: fix.U62FromFloat(float32(60)).S17Bits()},
+			// 	osc.Sine(rate, 12),
+			// ),
+		),
+		fxp.Concurrently(
+			// generate an envelope
+			fxp.Serially(
+				fxp.Every(1, 1*time.Second, rate),
+				env.AttackDecay(50*time.Millisecond, 200*time.Millisecond, rate),
+			),
+			// mix the oscillators together
+			fxp.Sum(1),
+		),
+		// apply the envelope
+		fxp.Amp{},
+	)
+	// send it to a delay with some feedback and wet/dry mix.
+	ch := fxp.NewGraph(0, 1)
+	var (
+		v   = ch.Add(voice)
+		fb  = ch.Add(fxp.Mixer{Gains: s17s(0.9921875, 0.5)})
+		d   = ch.Add(delay.NewDelay(1700*time.Millisecond, rate))
+		mix = ch.Add(fxp.Mixer{Gains: s17s(0.5, 0.5)})
+	)
+	for _, e := range []struct {
+		from fxp.Node
+		out  int
+		to   fxp.Node
+		in   int
+	}{
+		{v, 0, fb, 0},
+		{d, 0, fb, 1}, // feedback
+		{fb, 0, d, 0},
+		{v, 0, mix, 0},
+		{d, 0, mix, 1},
+		{mix, 0, fxp.GraphOutputs, 0},
+	} {
+		if err := ch.Connect(e.from, e.out, e.to, e.in); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := ch.Compile(); err != nil {
+		log.Fatal(err)
+	}
+	return ch
 }
 
 type copier struct {
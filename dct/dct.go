@@ -1,5 +1,6 @@
 // package dct implements a discrete cosine transform (DCT-II and DCT-III) on
-// fix.S17s.
+// fix.S17s. DCT is the fast one, Matrix and Transform work for any size but
+// take O(N^2).
 package dct
 
 import (
@@ -9,7 +10,8 @@ import (
 )
 
 // Matrix builds a DCT matrix. The result is square with size n, and each element
-// [i][j] = cos(pi/n * (j + 1/2) * i).
+// [i][j] = cos(pi/n * (j + 1/2) * i). For power of two sizes DCT doesn't need
+// one at all.
 // TODO: we almost definitely don't need the whole matrix.
 func Matrix(n int) [][]fix.S17 {
 	out := make([][]fix.S17, n)
@@ -26,14 +28,15 @@ func Matrix(n int) [][]fix.S17 {
 	return out
 }
 
-// TODO: use an algorithm that isn't O(N^2)
+// Transform does the DCT-II of in with a matrix from Matrix, saturating the
+// results. For power of two sizes DCT is much faster and more precise.
 func Transform(in, out []fix.S17, mat [][]fix.S17) {
 	// This is just a matrix multiply.
 	for i := range out {
-		var acc fix.S17 = 0
+		var acc fix.Acc32
 		for j, c := range mat[i] {
-			acc = acc.SAdd(c.SMul(in[j]))
+			acc = acc.MAC(c, in[j])
 		}
-		out[i] = acc
+		out[i] = acc.S17()
 	}
 }
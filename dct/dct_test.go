@@ -3,6 +3,7 @@ package dct
 import (
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"testing"
 
 	"github.com/pfcm/fxp/fix"
@@ -34,3 +35,117 @@ func TestTransform(t *testing.T) {
 
 	t.Fatal(out)
 }
+
+// naive returns the DCT-II of x in floats.
+func naive(x []float64) []float64 {
+	n := len(x)
+	out := make([]float64, n)
+	for k := range out {
+		for j, v := range x {
+			out[k] += v * math.Cos(math.Pi/float64(n)*(float64(j)+0.5)*float64(k))
+		}
+	}
+	return out
+}
+
+// signals returns some test signals of length n.
+func signals(n int) map[string][]fix.S17 {
+	r := rand.New(rand.NewPCG(1, 2))
+	sigs := map[string][]fix.S17{
+		"silence": make([]fix.S17, n),
+		"sine":    make([]fix.S17, n),
+		"noise":   make([]fix.S17, n),
+		"quiet":   make([]fix.S17, n),
+		"full":    make([]fix.S17, n),
+	}
+	for i := range n {
+		sigs["sine"][i] = fix.FromFloat(0.9 * math.Sin(2*math.Pi*3*float64(i)/float64(n)))
+		sigs["noise"][i] = fix.S17(r.IntN(256) - 128)
+		sigs["quiet"][i] = fix.S17(r.IntN(5) - 2)
+		sigs["full"][i] = fix.MinS17
+	}
+	return sigs
+}
+
+func TestDCTForward(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 32, 256} {
+		d, err := New(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, sig := range signals(n) {
+			x := make([]float64, n)
+			for i, s := range sig {
+				x[i] = fix.Float[float64](s)
+			}
+			want := naive(x)
+			got := make([]fix.S17, n)
+			exp := d.Forward(got, sig)
+			// Within a bit over half a step of the mantissas.
+			step := math.Ldexp(1.0/128, exp)
+			for k := range want {
+				g := fix.Float[float64](got[k]) * math.Ldexp(1, exp)
+				if math.Abs(g-want[k]) > 0.6*step+1e-9 {
+					t.Errorf("%d %s: X[%d] = %v * 2^%d = %v, want: %v", n, name, k, got[k], exp, g, want[k])
+				}
+			}
+		}
+	}
+}
+
+func TestDCTRoundTrip(t *testing.T) {
+	for _, n := range []int{1, 2, 8, 64, 512} {
+		d, err := New(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, sig := range signals(n) {
+			coeffs, got := make([]fix.S17, n), make([]fix.S17, n)
+			exp := d.Forward(coeffs, sig)
+			d.Inverse(got, coeffs, exp)
+			// The coefficients only have 8 bits, so the noise
+			// that adds can come through as a few LSBs.
+			var sum float64
+			for i := range sig {
+				e := float64(got[i]) - float64(sig[i])
+				sum += e * e
+				if math.Abs(e) > 8 {
+					t.Errorf("%d %s: out[%d] = %v, want: %v", n, name, i, got[i], sig[i])
+				}
+			}
+			if rms := math.Sqrt(sum / float64(n)); rms > 2 {
+				t.Errorf("%d %s: RMS error %v LSBs", n, name, rms)
+			}
+		}
+	}
+}
+
+func TestDCTSizes(t *testing.T) {
+	for _, n := range []int{0, -4, 3, 100} {
+		if _, err := New(n); err == nil {
+			t.Errorf("New(%d) succeeded", n)
+		}
+	}
+}
+
+func BenchmarkDCT(b *testing.B) {
+	for _, n := range []int{64, 512} {
+		d, err := New(n)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sig := signals(n)["noise"]
+		out := make([]fix.S17, n)
+		b.Run(fmt.Sprintf("fast/%d", n), func(b *testing.B) {
+			for range b.N {
+				d.Forward(out, sig)
+			}
+		})
+		m := Matrix(n)
+		b.Run(fmt.Sprintf("matrix/%d", n), func(b *testing.B) {
+			for range b.N {
+				Transform(sig, out, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package dct
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// DCT does fast DCT-IIs and their inverses, DCT-IIIs, of a power of two
+// size. It works in block floating point: the coefficients share an exponent,
+// so quiet and loud blocks both get the full 8 bits. It keeps buffers between
+// calls, so it can't be used concurrently.
+type DCT struct {
+	n, log2n int
+	// cos and sin hold the twiddles with 30 fractional bits: the first
+	// n/2 turn by -2πj/n for the FFT, the rest are the n quarter turns
+	// by πk/2n that go between the FFT and the DCT.
+	fftCos, fftSin []int64
+	rotCos, rotSin []int64
+	// re and im are the FFT's working space.
+	re, im []int64
+}
+
+// inBits is how far samples are shifted up on the way in, leaving room for
+// the FFT to grow before it has to scale.
+const inBits = 20
+
+// twiddleBits is the number of fractional bits in the twiddles.
+const twiddleBits = 30
+
+// New returns a DCT of size n, which needs to be a power of two.
+func New(n int) (*DCT, error) {
+	if n <= 0 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("DCT size %d isn't a power of two", n)
+	}
+	d := &DCT{
+		n:      n,
+		log2n:  bits.TrailingZeros(uint(n)),
+		fftCos: make([]int64, n/2),
+		fftSin: make([]int64, n/2),
+		rotCos: make([]int64, n),
+		rotSin: make([]int64, n),
+		re:     make([]int64, n),
+		im:     make([]int64, n),
+	}
+	const one = 1 << twiddleBits
+	for j := range d.fftCos {
+		a := -2 * math.Pi * float64(j) / float64(n)
+		d.fftCos[j] = int64(math.Round(math.Cos(a) * one))
+		d.fftSin[j] = int64(math.Round(math.Sin(a) * one))
+	}
+	for k := range d.rotCos {
+		a := math.Pi * float64(k) / float64(2*n)
+		d.rotCos[k] = int64(math.Round(math.Cos(a) * one))
+		d.rotSin[k] = int64(math.Round(math.Sin(a) * one))
+	}
+	return d, nil
+}
+
+// Len returns the size of the transform.
+func (d *DCT) Len() int { return d.n }
+
+// Forward sets dst to the DCT-II of src, X[k] = Σ src[j] cos(π/n (j + 1/2) k),
+// with each X[k] = dst[k] * 2^exp. Both need to be Len() long.
+func (d *DCT) Forward(dst, src []fix.S17) (exp int) {
+	// Makhoul's trick: the evens then the odds backwards, which makes
+	// the DCT the real part of a rotated FFT.
+	n := d.n
+	for j := range n / 2 {
+		d.re[j] = int64(src[2*j]) << inBits
+		d.re[n-1-j] = int64(src[2*j+1]) << inBits
+	}
+	if n == 1 {
+		d.re[0] = int64(src[0]) << inBits
+	}
+	clear(d.im)
+	e := d.fft(false)
+	for k := range n {
+		d.re[k] = (d.re[k]*d.rotCos[k] + d.im[k]*d.rotSin[k]) >> twiddleBits
+	}
+	return e + d.narrow(dst, d.re[:n])
+}
+
+// Inverse sets dst to the DCT-III of the coefficients src[k] * 2^exp, scaled
+// so that it undoes Forward. Both need to be Len() long.
+func (d *DCT) Inverse(dst, src []fix.S17, exp int) {
+	n := d.n
+	// Undo the rotation, which needs X[k] and X[n-k] to get back the
+	// imaginary part.
+	for k := range n {
+		x := int64(src[k]) << inBits
+		var y int64
+		if k > 0 {
+			y = int64(src[n-k]) << inBits
+		}
+		d.re[k] = (x*d.rotCos[k] + y*d.rotSin[k]) >> twiddleBits
+		d.im[k] = (x*d.rotSin[k] - y*d.rotCos[k]) >> twiddleBits
+	}
+	e := d.fft(true)
+	// The inverse FFT divides by n.
+	shift := inBits - e - exp + d.log2n
+	for j := range n / 2 {
+		dst[2*j] = shiftS17(d.re[j], shift)
+		dst[2*j+1] = shiftS17(d.re[n-1-j], shift)
+	}
+	if n == 1 {
+		dst[0] = shiftS17(d.re[0], shift)
+	}
+}
+
+// fft transforms re and im in place, or does the inverse without dividing by
+// n. It scales down by two whenever the values get big enough that the next
+// stage could overflow, and returns the number of times it did.
+func (d *DCT) fft(inverse bool) (exp int) {
+	n := d.n
+	re, im := d.re[:n], d.im[:n]
+	for i := range n {
+		j := int(bits.Reverse(uint(i)) >> (bits.UintSize - d.log2n))
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		// A stage can grow the biggest value by 1 + √2, so keep
+		// everything under 2^(inBits+8) going in.
+		if maxAbs(re, im) >= 1<<(inBits+8) {
+			for i := range re {
+				re[i] >>= 1
+				im[i] >>= 1
+			}
+			exp++
+		}
+		step := n / size
+		for start := 0; start < n; start += size {
+			for j := range size / 2 {
+				c, s := d.fftCos[j*step], d.fftSin[j*step]
+				if inverse {
+					s = -s
+				}
+				a, b := start+j, start+j+size/2
+				tr := (re[b]*c - im[b]*s) >> twiddleBits
+				ti := (re[b]*s + im[b]*c) >> twiddleBits
+				re[b], im[b] = re[a]-tr, im[a]-ti
+				re[a], im[a] = re[a]+tr, im[a]+ti
+			}
+		}
+	}
+	return exp
+}
+
+// narrow rounds vs, which have inBits more fractional bits than an S17, into
+// dst with the smallest exponent that fits them all, and returns it.
+func (d *DCT) narrow(dst []fix.S17, vs []int64) (exp int) {
+	m := int64(0)
+	for _, v := range vs {
+		m = max(m, v, -v)
+	}
+	if m == 0 {
+		clear(dst)
+		return 0
+	}
+	// The shift that gets the biggest into 7 bits, unless it rounds up
+	// out of them.
+	shift := bits.Len64(uint64(m)) - 7
+	if shift > 0 && (m+1<<(shift-1))>>shift > int64(fix.MaxS17) {
+		shift++
+	}
+	for i, v := range vs {
+		dst[i] = shiftS17(v, shift)
+	}
+	return shift - inBits
+}
+
+func maxAbs(re, im []int64) int64 {
+	m := int64(0)
+	for i := range re {
+		m = max(m, re[i], -re[i], im[i], -im[i])
+	}
+	return m
+}
+
+// shiftS17 returns v shifted down by shift, or up if it's negative, rounded
+// to the nearest S17 and saturating.
+func shiftS17(v int64, shift int) fix.S17 {
+	switch {
+	case shift > 62:
+		return 0
+	case shift > 0:
+		v = (v + 1<<(shift-1)) >> shift
+	case shift < 0:
+		v = min(max(v, math.MinInt32), math.MaxInt32) << min(-shift, 31)
+	}
+	return fix.S17(min(max(v, int64(fix.MinS17)), int64(fix.MaxS17)))
+}
@@ -5,9 +5,21 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/pfcm/fxp"
 	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/patch"
 )
 
+func init() {
+	patch.Register("delay.Delay", func(env patch.Env, p patch.Params) (fxp.Ticker, error) {
+		t, err := p.Duration("time", 0)
+		if err != nil {
+			return nil, err
+		}
+		return New(t, env.SampleRate)
+	})
+}
+
 // ring is an interpolating ring buffer.
 // TODO: figure out an api for a multi-tap
 type ring struct {
@@ -28,9 +40,9 @@ func newRing(size int) *ring {
 
 // write writes a chunk of samples to the buffer at the current position of the
 // write head. Updates the write head.
-func (r *ring) write(in []fix.S17) {
+func (r *ring) write(in []fix.S17) error {
 	if len(in) > len(r.buf) {
-		panic(fmt.Errorf("input %d larger than buffer %d", len(in), len(r.buf)))
+		return fmt.Errorf("input %d larger than buffer %d", len(in), len(r.buf))
 	}
 	copied := copy(r.buf[r.writep:], in)
 	if copied < len(in) {
@@ -39,13 +51,14 @@ func (r *ring) write(in []fix.S17) {
 	} else {
 		r.writep += copied
 	}
+	return nil
 }
 
 // read reads a chunk of samples from the buffer at the current read
 // head. Advances the read head.
-func (r *ring) read(out []fix.S17) {
+func (r *ring) read(out []fix.S17) error {
 	if len(out) > len(r.buf) {
-		panic(fmt.Errorf("output %d larger than buffer %d", len(out), len(r.buf)))
+		return fmt.Errorf("output %d larger than buffer %d", len(out), len(r.buf))
 	}
 	copied := copy(out, r.buf[r.readp:])
 	if copied < len(out) {
@@ -54,6 +67,7 @@ func (r *ring) read(out []fix.S17) {
 	} else {
 		r.readp += copied
 	}
+	return nil
 }
 
 // Delay is an fxp.Ticker that provides a simple tape-style delay.
@@ -63,16 +77,50 @@ type Delay struct {
 	rb *ring
 }
 
-func NewDelay(maxTime time.Duration, samplerate float32) *Delay {
+var (
+	_ fxp.Ticker    = &Delay{}
+	_ fxp.Validator = &Delay{}
+)
+
+// New returns a Delay of maxTime, or an error if that is less than a sample.
+func New(maxTime time.Duration, samplerate float32) (*Delay, error) {
 	samps := int(maxTime.Seconds() * float64(samplerate))
-	return &Delay{rb: newRing(samps)}
+	if samps <= 0 {
+		return nil, fmt.Errorf("delay of %v at %vHz is %d samples", maxTime, samplerate, samps)
+	}
+	return &Delay{rb: newRing(samps)}, nil
+}
+
+// NewDelay is like New, but panics on error.
+func NewDelay(maxTime time.Duration, samplerate float32) *Delay {
+	d, err := New(maxTime, samplerate)
+	if err != nil {
+		panic(err)
+	}
+	return d
 }
 
 func (*Delay) Inputs() int      { return 1 }
 func (*Delay) Outputs() int     { return 1 }
 func (d *Delay) String() string { return fmt.Sprintf("Delay(%d)", len(d.rb.buf)) }
 
+func (d *Delay) Validate() error {
+	if d.rb == nil || len(d.rb.buf) == 0 {
+		return fmt.Errorf("zero length delay")
+	}
+	return nil
+}
+
 func (d *Delay) Tick(in, out [][]fix.S17) {
-	d.rb.read(out[0])
-	d.rb.write(in[0])
+	// Blocks longer than the delay have to be done in pieces that fit in
+	// the ring, which the ring checks.
+	for start := 0; start < len(in[0]); start += len(d.rb.buf) {
+		end := min(len(in[0]), start+len(d.rb.buf))
+		if err := d.rb.read(out[0][start:end]); err != nil {
+			panic(err)
+		}
+		if err := d.rb.write(in[0][start:end]); err != nil {
+			panic(err)
+		}
+	}
 }
@@ -0,0 +1,96 @@
+package delay
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/interp"
+)
+
+func TestDelayBlockSizes(t *testing.T) {
+	for _, block := range []int{1, 3, 10, 25} {
+		d := &Delay{rb: newRing(10)}
+		const total = 60
+		in := make([]fix.S17, total)
+		for i := range in {
+			in[i] = fix.S17(i + 1)
+		}
+		out := make([]fix.S17, total)
+		for start := 0; start < total; start += block {
+			end := min(total, start+block)
+			d.Tick([][]fix.S17{in[start:end]}, [][]fix.S17{out[start:end]})
+		}
+		for i, got := range out {
+			var want fix.S17
+			if i >= 10 {
+				want = in[i-10]
+			}
+			if got != want {
+				t.Errorf("block %d: out[%d] = %v, want: %v", block, i, got, want)
+			}
+		}
+	}
+}
+
+func TestRingErrors(t *testing.T) {
+	r := newRing(4)
+	if err := r.write(make([]fix.S17, 5)); err == nil {
+		t.Error("write of 5 samples into ring of 4 succeeded")
+	}
+	if err := r.read(make([]fix.S17, 5)); err == nil {
+		t.Error("read of 5 samples from ring of 4 succeeded")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(0, 44100); err == nil {
+		t.Error("New(0, 44100) succeeded")
+	}
+	if _, err := New(1, 44100); err == nil {
+		t.Error("New(1ns, 44100) succeeded")
+	}
+}
+
+func TestFractional(t *testing.T) {
+	for _, c := range []struct {
+		in  interp.Interpolator
+		ctl fix.S17
+		// delay is the delay it should make, in samples.
+		delay float64
+	}{
+		// 64 samples at most, so each step of the control is 64/127
+		// of a sample.
+		{interp.Linear{}, 10, 640.0 / 127},
+		{interp.Linear{}, 11, 704.0 / 127},
+		{interp.Hermite{}, 11, 704.0 / 127},
+		{interp.Lagrange{}, 127, 64},
+		// Too short for the interpolators, which need to see ahead.
+		{interp.Linear{}, 0, 1},
+		{interp.Hermite{}, -3, 2},
+	} {
+		d, err := NewFractional(64*time.Millisecond, 1000, c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A ramp, which all the interpolators get right.
+		const n = 200
+		in, ctl := make([]fix.S17, n), make([]fix.S17, n)
+		for i := range in {
+			in[i] = fix.S17(i - 100)
+			ctl[i] = c.ctl
+		}
+		out := [][]fix.S17{make([]fix.S17, n)}
+		d.Tick([][]fix.S17{in, ctl}, out)
+		for i := 70; i < n; i++ {
+			if got, want := float64(out[0][i]), float64(i)-c.delay-100; math.Abs(got-want) > 0.5 {
+				t.Errorf("%v, %v: out[%d] = %v, want: %v", c.in, c.ctl, i, got, want)
+				break
+			}
+		}
+	}
+	if _, err := NewFractional(0, 44100, interp.Linear{}); err == nil {
+		t.Error("NewFractional accepted a zero length delay")
+	}
+}
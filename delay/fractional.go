@@ -0,0 +1,95 @@
+package delay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/interp"
+	"github.com/pfcm/fxp/patch"
+)
+
+func init() {
+	patch.Register("delay.Fractional", func(env patch.Env, p patch.Params) (fxp.Ticker, error) {
+		t, err := p.Duration("time", 0)
+		if err != nil {
+			return nil, err
+		}
+		name, err := p.String("interp", "hermite")
+		if err != nil {
+			return nil, err
+		}
+		in, err := interp.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		return NewFractional(t, env.SampleRate, in)
+	})
+}
+
+// Fractional is a delay line with a time that can change every sample and
+// needn't be a whole number of samples. Its first input is the signal and
+// the second sets the time: 0 or below for the shortest delay the
+// interpolator allows, up to fix.MaxS17 for the longest.
+type Fractional struct {
+	in interp.Interpolator
+	// buf is a power of two long, so positions wrap with mask.
+	buf  []fix.S17
+	mask int
+	// pos is the number of samples written.
+	pos int
+	// longest is the longest delay, shortest the shortest the
+	// interpolator can manage, in samples with 16 fractional bits.
+	longest, shortest int64
+	// x holds the samples to interpolate.
+	x [interp.MaxTaps]fix.S17
+}
+
+var _ fxp.Ticker = &Fractional{}
+
+// NewFractional returns a Fractional delay of up to maxTime, or an error if
+// that is less than a sample.
+func NewFractional(maxTime time.Duration, samplerate float32, in interp.Interpolator) (*Fractional, error) {
+	samps := int(maxTime.Seconds() * float64(samplerate))
+	if samps <= 0 {
+		return nil, fmt.Errorf("delay of %v at %vHz is %d samples", maxTime, samplerate, samps)
+	}
+	// The interpolator needs to see this far past the point it's
+	// interpolating.
+	ahead := in.Taps() - in.Offset() - 1
+	size := 1
+	for size < samps+in.Taps()+1 {
+		size <<= 1
+	}
+	return &Fractional{
+		in:       in,
+		buf:      make([]fix.S17, size),
+		mask:     size - 1,
+		longest:  int64(samps) << 16,
+		shortest: int64(ahead) << 16,
+	}, nil
+}
+
+func (*Fractional) Inputs() int  { return 2 }
+func (*Fractional) Outputs() int { return 1 }
+func (f *Fractional) String() string {
+	return fmt.Sprintf("Fractional(%d, %v)", f.longest>>16, f.in)
+}
+
+func (f *Fractional) Tick(in, out [][]fix.S17) {
+	x := f.x[:f.in.Taps()]
+	for i, s := range in[0] {
+		f.buf[f.pos&f.mask] = s
+		d := max(f.longest*int64(max(in[1][i], 0))/int64(fix.MaxS17), f.shortest)
+		// The point to read, which is never after the sample just
+		// written.
+		p := int64(f.pos)<<16 - d
+		j := int(p>>16) - f.in.Offset()
+		for k := range x {
+			x[k] = f.buf[(j+k)&f.mask]
+		}
+		out[0][i] = f.in.At(x, fix.U016(p))
+		f.pos++
+	}
+}
@@ -2,12 +2,23 @@
 package env
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/pfcm/fxp"
 	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/patch"
 )
 
+func init() {
+	patch.Register("env.AD", func(env patch.Env, p patch.Params) (fxp.Ticker, error) {
+		a, err1 := p.Duration("attack", 10*time.Millisecond)
+		d, err2 := p.Duration("decay", 100*time.Millisecond)
+		return AttackDecay(a, d, env.SampleRate), errors.Join(err1, err2)
+	})
+}
+
 type envState byte
 
 const (
@@ -25,15 +36,19 @@ const (
 type AD struct {
 	nAttack int // in samples
 	nDecay  int
-	state   envState
-	counter int
+	// 1/nAttack and 1/nDecay, see ramp.
+	attackStep, decayStep uint64
+	state                 envState
+	counter               int
 }
 
 func AttackDecay(attack, decay time.Duration, samplerate float32) *AD {
-	return &AD{
+	a := &AD{
 		nAttack: int(attack.Seconds() * float64(samplerate)),
 		nDecay:  int(decay.Seconds() * float64(samplerate)),
 	}
+	a.attackStep, a.decayStep = rampStep(a.nAttack), rampStep(a.nDecay)
+	return a
 }
 
 func (*AD) Inputs() int      { return 1 }
@@ -47,13 +62,13 @@ func (a *AD) Tick(in, out [][]fix.S17) {
 		}
 		switch a.state {
 		case attack:
-			out[0][i] = pos(0, a.counter, a.nAttack)
+			out[0][i] = ramp(a.counter, a.attackStep)
 			a.counter++
 			if a.counter >= a.nAttack {
 				a.enter(decay)
 			}
 		case decay:
-			out[0][i] = pos(0, a.nDecay-a.counter-1, a.nDecay)
+			out[0][i] = ramp(a.nDecay-a.counter-1, a.decayStep)
 			a.counter++
 			if a.counter >= a.nDecay {
 				a.enter(idle)
@@ -69,9 +84,15 @@ func (a *AD) enter(state envState) {
 	a.counter = 0
 }
 
-// pos returns a coefficient between 0 and 1 depending on where pos is between
-// start and end.
-func pos(start, pos, end int) fix.S17 {
-	f := float32(pos-start) / float32(end-start)
-	return fix.FromFloat(f)
+// rampStep returns 1/n with 32 fractional bits, for ramp. Passing n as the
+// raw bits of a fix.U1616 gets the extra 16 bits of precision we need for
+// long ramps.
+func rampStep(n int) uint64 {
+	return uint64(fix.Recip(fix.U1616(min(n, int(fix.MaxU1616)))))
+}
+
+// ramp returns a coefficient between 0 and 1 depending on how far i is
+// through a ramp with the provided step.
+func ramp(i int, step uint64) fix.S17 {
+	return fix.S17(min(uint64(i)*step>>(32-7), uint64(fix.MaxS17)))
 }
@@ -0,0 +1,32 @@
+package env
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+func TestRamp(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 100, 441, 44100, 100000} {
+		step := rampStep(n)
+		for i := 0; i < n; i += max(1, n/1000) {
+			want := fix.FromFloat(float64(i) / float64(n))
+			if got := ramp(i, step); got != want && got != want-1 {
+				t.Fatalf("ramp(%d) of %d = %v, want: %v", i, n, got, want)
+			}
+		}
+	}
+}
+
+func TestAD(t *testing.T) {
+	ad := AttackDecay(4*time.Millisecond, 2*time.Millisecond, 1000) // 4 and 2 samples
+	in := []fix.S17{1, 0, 0, 0, 0, 0, 0, 0}
+	out := [][]fix.S17{make([]fix.S17, len(in))}
+	ad.Tick([][]fix.S17{in}, out)
+	for i, want := range []fix.S17{0, 32, 64, 96, 64, 0, 0, 0} {
+		if out[0][i] != want {
+			t.Errorf("out[0][%d] = %v, want: %v", i, out[0][i], want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package fix
+
+import "fmt"
+
+// Acc16 is a wide accumulator for adding up S17s without clipping along the
+// way. It has the same 7 fractional bits as S17 and 9 integer bits, so it
+// holds -256 to just under 256: over 200 full scale S17s. Products are
+// rounded down to 7 fractional bits as they're added, see Acc32 to keep them
+// exact.
+type Acc16 int16
+
+// Add adds an S17, saturating if the accumulator overflows.
+func (a Acc16) Add(s S17) Acc16 {
+	return Acc16(clamp(int32(a)+int32(s), math16Min, math16Max))
+}
+
+// MAC adds x * y, saturating if the accumulator overflows.
+func (a Acc16) MAC(x, y S17) Acc16 {
+	p := (int32(x) * int32(y)) >> 7
+	return Acc16(clamp(int32(a)+p, math16Min, math16Max))
+}
+
+// S17 narrows the accumulator to an S17, saturating if it's out of range.
+func (a Acc16) S17() S17 {
+	return S17(clamp(a, Acc16(MinS17), Acc16(MaxS17)))
+}
+
+func (a Acc16) String() string {
+	return fmt.Sprintf("%.7f", float64(a)/(1<<7))
+}
+
+const (
+	math16Min = -1 << 15
+	math16Max = 1<<15 - 1
+)
+
+// Acc32 is a wide accumulator for sums of products of S17s. It has 14
+// fractional bits, so products are added exactly, and 18 integer bits, so
+// it can add up over 100,000 full scale products before it saturates.
+type Acc32 int32
+
+// Add adds an S17, saturating if the accumulator overflows.
+func (a Acc32) Add(s S17) Acc32 {
+	return a.add(int64(s) << 7)
+}
+
+// MAC adds x * y exactly, saturating if the accumulator overflows.
+func (a Acc32) MAC(x, y S17) Acc32 {
+	return a.add(int64(x) * int64(y))
+}
+
+func (a Acc32) add(v int64) Acc32 {
+	return Acc32(clamp(int64(a)+v, -1<<31, 1<<31-1))
+}
+
+// S17 narrows the accumulator to an S17, rounding down and saturating if
+// it's out of range.
+func (a Acc32) S17() S17 {
+	return a.S17R(Floor)
+}
+
+// S17R is S17 with the provided rounding.
+func (a Acc32) S17R(r Rounding) S17 {
+	v := int64(a)
+	switch r {
+	case Nearest:
+		v += 1 << 6
+	case Convergent:
+		v += 1<<6 - 1 + (v>>7)&1
+	}
+	return S17(clamp(v>>7, int64(MinS17), int64(MaxS17)))
+}
+
+func (a Acc32) String() string {
+	return fmt.Sprintf("%.14f", float64(a)/(1<<14))
+}
@@ -0,0 +1,64 @@
+package fix
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAcc16(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		acc  Acc16
+		want S17
+	}{
+		{"clips", Acc16(0).Add(100).Add(100), MaxS17},
+		{"no early clipping", Acc16(0).Add(100).Add(100).Add(-120), 80},
+		{"negative", Acc16(0).Add(MinS17).Add(MinS17).Add(100), MinS17},
+		{"mac", Acc16(0).MAC(64, 64).MAC(64, 64), 64},
+		{"mac floors each product", Acc16(0).MAC(1, 64).MAC(1, 64), 0},
+	} {
+		if got := c.acc.S17(); got != c.want {
+			t.Errorf("%s: %v.S17() = %v, want: %v", c.name, c.acc, got, c.want)
+		}
+	}
+	// It saturates rather than wrapping.
+	var a Acc16
+	for range 300 {
+		a = a.Add(MaxS17)
+	}
+	if a != math16Max {
+		t.Errorf("300 * MaxS17 = %v, want: %v", a, Acc16(math16Max))
+	}
+}
+
+func TestAcc32(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		acc  Acc32
+		r    Rounding
+		want S17
+	}{
+		{"clips", Acc32(0).MAC(MinS17, MinS17), Floor, MaxS17},
+		{"no early clipping", Acc32(0).MAC(MinS17, MinS17).MAC(MinS17, 64), Floor, 64},
+		{"exact products", Acc32(0).MAC(1, 64).MAC(1, 64), Floor, 1},
+		{"floor", Acc32(0).MAC(-1, 64).MAC(-1, 1), Floor, -1},
+		{"nearest", Acc32(0).MAC(1, 64).Add(3), Nearest, 4},
+		{"convergent", Acc32(0).MAC(1, 64).Add(3), Convergent, 4},
+		{"convergent down", Acc32(0).MAC(1, 64).Add(2), Convergent, 2},
+		{"add", Acc32(0).Add(-100).Add(-100).Add(120), Floor, -80},
+	} {
+		if got := c.acc.S17R(c.r); got != c.want {
+			t.Errorf("%s: %v.S17R(%v) = %v, want: %v", c.name, c.acc, c.r, got, c.want)
+		}
+	}
+	// Sums of products are only rounded once, at the end.
+	for i := int(MinS17); i <= int(MaxS17); i++ {
+		for j := int(MinS17); j <= int(MaxS17); j++ {
+			a := Acc32(0).MAC(S17(i), S17(j)).MAC(S17(i), S17(j)).MAC(S17(i), S17(j))
+			want := S17(min(max(math.Floor(float64(3*i*j)/128), -128), 127))
+			if got := a.S17(); got != want {
+				t.Fatalf("3 * %v * %v = %v, want: %v", S17(i), S17(j), got, want)
+			}
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package fix
 
 import (
+	"cmp"
 	"fmt"
 
 	"golang.org/x/exp/constraints"
 )
 
+//go:generate go run ./internal/gen
+
 // S17 is a signed (two's complement) 8 bit number with 1 integer bit and 7 franctional
 // bits capable of representing (roughly) the range -1 to 1.
 type S17 int8
@@ -23,20 +26,56 @@ func (s S17) String() string {
 
 // SAdd is a saturating +, clipping to the minimum or maximum value.
 func (a S17) SAdd(b S17) S17 {
-	// TODO: this can definitely be more efficient
-	if a > 0 && b > 0 && a > MaxS17-b {
-		return MaxS17
-	}
-	if a < 0 && b < 0 && a < MinS17+b {
-		return MinS17
-	}
-	return a + b
+	return S17(clamp(int16(a)+int16(b), int16(MinS17), int16(MaxS17)))
 }
 
 // SMul multiplies an S17 with another, saturating at the maximum or minimum
-// if it overflows.
+// if it overflows. The result is rounded down, see SMulR for other options.
 func (a S17) SMul(b S17) S17 {
-	return S17((int16(a) * int16(b)) >> 7)
+	return a.SMulR(b, Floor)
+}
+
+// Rounding says what to do with the bits that don't fit when the result of
+// an operation has more precision than its type.
+type Rounding int
+
+const (
+	// Floor rounds towards negative infinity, which is the cheapest but
+	// biases results downwards by half an LSB on average.
+	Floor Rounding = iota
+	// Nearest rounds to the nearest value, with halves rounding up.
+	Nearest
+	// Convergent rounds to the nearest value, with halves rounding to
+	// even, so there's no bias at all.
+	Convergent
+)
+
+func (r Rounding) String() string {
+	switch r {
+	case Floor:
+		return "Floor"
+	case Nearest:
+		return "Nearest"
+	case Convergent:
+		return "Convergent"
+	}
+	return fmt.Sprintf("Rounding(%d)", int(r))
+}
+
+// SMulR is SMul with the provided rounding.
+func (a S17) SMulR(b S17, r Rounding) S17 {
+	// The product has 14 fractional bits, and the only one that doesn't
+	// fit back into an S17 is -1 * -1.
+	p := int16(a) * int16(b)
+	switch r {
+	case Nearest:
+		p += 1 << 6
+	case Convergent:
+		// Only carries from exactly a half if the result would
+		// otherwise be odd.
+		p += 1<<6 - 1 + (p>>7)&1
+	}
+	return S17(clamp(p>>7, int16(MinS17), int16(MaxS17)))
 }
 
 func Float[T constraints.Float](s S17) T {
@@ -56,34 +95,10 @@ func FromFloat[T constraints.Float](f T) S17 {
 	return S17(f * T(1<<7))
 }
 
-// U62 is an unsigned fixed point number with 6 integer bits and 2 fractional bits,
-// capable of representing 0 to 63.75.
-type U62 uint8
-
-const (
-	// MaxU62 is the highest U62: 63.75
-	MaxU62 U62 = 0xFF
-	// MinU62 is the lowest U62: 0.
-	MinU62 U62 = 0x00
-)
-
-func (s U62) String() string {
-	return fmt.Sprintf("%.2f", U62ToFloat[float64](s))
-}
-
-func U62ToFloat[T constraints.Float](s U62) T {
-	// ideally this would be const, but apparently it can't be.
-	var scale = 1.0 / T(1<<2)
-	return T(s) * scale
-}
-
-// U62FromFloat converts a float into an U62, clamping to the maximum or minimum values.
-func U62FromFloat[T constraints.Float](f T) U62 {
-	if f < U62ToFloat[T](MinU62) {
-		return MinU62
-	}
-	if f > U62ToFloat[T](MaxU62) {
-		return MaxU62
+// clamp limits v to between lo and hi, recording a saturation if it had to.
+func clamp[T cmp.Ordered](v, lo, hi T) T {
+	if Counting && (v < lo || v > hi) {
+		Saturated()
 	}
-	return U62(f * T(1<<2))
+	return min(max(v, lo), hi)
 }
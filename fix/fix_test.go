@@ -1,6 +1,7 @@
 package fix
 
 import (
+	"math"
 	"testing"
 )
 
@@ -17,6 +18,8 @@ func TestS17SAdd(t *testing.T) {
 		{125, 10, 127},
 		{-126, 10, -116},
 		{-125, -10, -128},
+		{-126, -1, -127},
+		{-64, -64, -128},
 	} {
 		got := c.a.SAdd(c.b)
 		if got != c.out {
@@ -42,6 +45,9 @@ func TestS17SMul(t *testing.T) {
 		{s44(0.5), s44(0.5), s44(0.25)},
 		{s44(0.5), s44(-0.5), s44(-0.25)},
 		{s44(1.0), s44(0.5), s44(0.4921875)}, // 1.0 is slightly truncated
+		{MinS17, MinS17, MaxS17},
+		{MinS17, MaxS17, -MaxS17},
+		{s44(-0.5), 1, -1}, // rounds down, not towards zero
 	} {
 		got := c.a.SMul(c.b)
 		if got != c.out {
@@ -54,6 +60,29 @@ func TestS17SMul(t *testing.T) {
 	}
 }
 
+// TestS17SMulR checks every product against rounding the exact result.
+func TestS17SMulR(t *testing.T) {
+	for _, c := range []struct {
+		r     Rounding
+		round func(float64) float64
+	}{
+		{Floor, math.Floor},
+		{Nearest, func(f float64) float64 { return math.Floor(f + 0.5) }},
+		{Convergent, math.RoundToEven},
+	} {
+		for i := int(MinS17); i <= int(MaxS17); i++ {
+			for j := int(MinS17); j <= int(MaxS17); j++ {
+				a, b := S17(i), S17(j)
+				exact := Float[float64](a) * Float[float64](b) * (1 << 7)
+				want := S17(min(max(c.round(exact), float64(MinS17)), float64(MaxS17)))
+				if got := a.SMulR(b, c.r); got != want {
+					t.Fatalf("%v SMulR(%v, %v) = %v, want: %v", a, b, c.r, got, want)
+				}
+			}
+		}
+	}
+}
+
 func TestFromFloat(t *testing.T) {
 	for _, c := range []struct {
 		in  float64
@@ -80,3 +109,45 @@ func TestS17Float32RoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestS115(t *testing.T) {
+	for _, c := range []struct {
+		a, b      S115
+		sum, prod S115
+	}{
+		{0, 0, 0, 0},
+		{0x4000, 0x4000, 0x7FFF, 0x2000},
+		{-0x4000, 0x4000, 0, -0x2000},
+		{MinS115, -1, MinS115, 1},
+		{MinS115, MinS115, MinS115, MaxS115},
+	} {
+		if got := c.a.SAdd(c.b); got != c.sum {
+			t.Errorf("%v SAdd %v = %v, want: %v", c.a, c.b, got, c.sum)
+		}
+		if got := c.a.SMul(c.b); got != c.prod {
+			t.Errorf("%v SMul %v = %v, want: %v", c.a, c.b, got, c.prod)
+		}
+	}
+	for i := int(MinS17); i <= int(MaxS17); i++ {
+		s := S17(i)
+		if got := s.S115().S17(); got != s {
+			t.Errorf("%v: S115().S17() = %v", s, got)
+		}
+	}
+}
+
+func TestSaturations(t *testing.T) {
+	before := Saturations()
+	MaxS17.SAdd(1)
+	MinS17.SMul(MinS17)
+	S115(MaxS115).SAdd(1)
+	Acc32(1 << 20).S17()
+	MaxS17.SAdd(-1)
+	var want uint64
+	if Counting {
+		want = 4
+	}
+	if got := Saturations() - before; got != want {
+		t.Errorf("counted %d saturations, want: %d", got, want)
+	}
+}
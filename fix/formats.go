@@ -0,0 +1,488 @@
+// Code generated by internal/gen; DO NOT EDIT.
+
+package fix
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/constraints"
+)
+
+// U62 is an unsigned 8 bit fixed point number with 6 integer bits
+// and 2 fractional bits, covering 0 to 63.75.
+// It holds midi notes for package osc.
+type U62 uint8
+
+const (
+	// MaxU62 is the highest U62: 63.75.
+	MaxU62 U62 = 0xFF
+	// MinU62 is the lowest U62: 0.
+	MinU62 U62 = 0
+)
+
+func (a U62) String() string {
+	return fmt.Sprintf("%.2f", U62ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a U62) SAdd(b U62) U62 {
+	return U62(clamp(int32(a)+int32(b), int32(MinU62), int32(MaxU62)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a U62) SSub(b U62) U62 {
+	return U62(clamp(int32(a)-int32(b), int32(MinU62), int32(MaxU62)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a U62) SMul(b U62) U62 {
+	return U62(clamp((int32(a)*int32(b))>>2, int32(MinU62), int32(MaxU62)))
+}
+
+// S17 converts a U62 to an S17, rounding down and saturating if it's out
+// of range.
+func (a U62) S17() S17 {
+	return S17(clamp(int64(a)<<5, int64(MinS17), int64(MaxS17)))
+}
+
+// U62 converts an S17 to a U62, rounding down and saturating if it's out
+// of range.
+func (a S17) U62() U62 {
+	return U62(clamp(int64(a)>>5, int64(MinU62), int64(MaxU62)))
+}
+
+// S17Bits reinterprets a U62's bits as an S17, so that it can be carried
+// through an S17 stream and read back with U62FromS17Bits. The S17 doesn't
+// mean anything as audio.
+func (a U62) S17Bits() S17 {
+	return S17(a)
+}
+
+// U62FromS17Bits is the opposite of S17Bits.
+func U62FromS17Bits(s S17) U62 {
+	return U62(s)
+}
+
+func U62ToFloat[T constraints.Float](a U62) T {
+	var scale = 1.0 / T(1<<2)
+	return T(a) * scale
+}
+
+// U62FromFloat converts a float into a U62, clamping to the maximum or
+// minimum values.
+func U62FromFloat[T constraints.Float](f T) U62 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 2)
+	return U62(clamp(v, float64(MinU62), float64(MaxU62)))
+}
+
+// U08 is an unsigned 8 bit fixed point number with 0 integer bits
+// and 8 fractional bits, covering 0 to 0.99609375.
+// It suits gains and other things between 0 and 1.
+type U08 uint8
+
+const (
+	// MaxU08 is the highest U08: 0.99609375.
+	MaxU08 U08 = 0xFF
+	// MinU08 is the lowest U08: 0.
+	MinU08 U08 = 0
+)
+
+func (a U08) String() string {
+	return fmt.Sprintf("%.8f", U08ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a U08) SAdd(b U08) U08 {
+	return U08(clamp(int32(a)+int32(b), int32(MinU08), int32(MaxU08)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a U08) SSub(b U08) U08 {
+	return U08(clamp(int32(a)-int32(b), int32(MinU08), int32(MaxU08)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a U08) SMul(b U08) U08 {
+	return U08(clamp((int32(a)*int32(b))>>8, int32(MinU08), int32(MaxU08)))
+}
+
+// S17 converts a U08 to an S17, rounding down and saturating if it's out
+// of range.
+func (a U08) S17() S17 {
+	return S17(clamp(int64(a)>>1, int64(MinS17), int64(MaxS17)))
+}
+
+// U08 converts an S17 to a U08, rounding down and saturating if it's out
+// of range.
+func (a S17) U08() U08 {
+	return U08(clamp(int64(a)<<1, int64(MinU08), int64(MaxU08)))
+}
+
+// S17Bits reinterprets a U08's bits as an S17, so that it can be carried
+// through an S17 stream and read back with U08FromS17Bits. The S17 doesn't
+// mean anything as audio.
+func (a U08) S17Bits() S17 {
+	return S17(a)
+}
+
+// U08FromS17Bits is the opposite of S17Bits.
+func U08FromS17Bits(s S17) U08 {
+	return U08(s)
+}
+
+func U08ToFloat[T constraints.Float](a U08) T {
+	var scale = 1.0 / T(1<<8)
+	return T(a) * scale
+}
+
+// U08FromFloat converts a float into a U08, clamping to the maximum or
+// minimum values.
+func U08FromFloat[T constraints.Float](f T) U08 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 8)
+	return U08(clamp(v, float64(MinU08), float64(MaxU08)))
+}
+
+// S115 is a signed (two's complement) 16 bit fixed point number with 1 integer bits
+// and 15 fractional bits, covering -1 to 0.999969482421875.
+// It covers the same range as S17 with a lot more precision.
+type S115 int16
+
+const (
+	// MaxS115 is the highest S115: 0.999969482421875.
+	MaxS115 S115 = 0x7FFF
+	// MinS115 is the lowest S115: -1.
+	MinS115 S115 = -0x8000
+)
+
+func (a S115) String() string {
+	return fmt.Sprintf("%.15f", S115ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a S115) SAdd(b S115) S115 {
+	return S115(clamp(int32(a)+int32(b), int32(MinS115), int32(MaxS115)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a S115) SSub(b S115) S115 {
+	return S115(clamp(int32(a)-int32(b), int32(MinS115), int32(MaxS115)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a S115) SMul(b S115) S115 {
+	return S115(clamp((int32(a)*int32(b))>>15, int32(MinS115), int32(MaxS115)))
+}
+
+// S17 converts a S115 to an S17, rounding down and saturating if it's out
+// of range.
+func (a S115) S17() S17 {
+	return S17(clamp(int64(a)>>8, int64(MinS17), int64(MaxS17)))
+}
+
+// S115 converts an S17 to a S115, rounding down and saturating if it's out
+// of range.
+func (a S17) S115() S115 {
+	return S115(clamp(int64(a)<<8, int64(MinS115), int64(MaxS115)))
+}
+
+func S115ToFloat[T constraints.Float](a S115) T {
+	var scale = 1.0 / T(1<<15)
+	return T(a) * scale
+}
+
+// S115FromFloat converts a float into a S115, clamping to the maximum or
+// minimum values.
+func S115FromFloat[T constraints.Float](f T) S115 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 15)
+	return S115(clamp(v, float64(MinS115), float64(MaxS115)))
+}
+
+// U88 is an unsigned 16 bit fixed point number with 8 integer bits
+// and 8 fractional bits, covering 0 to 255.99609375.
+// It holds midi notes with much finer tuning than U62.
+type U88 uint16
+
+const (
+	// MaxU88 is the highest U88: 255.99609375.
+	MaxU88 U88 = 0xFFFF
+	// MinU88 is the lowest U88: 0.
+	MinU88 U88 = 0
+)
+
+func (a U88) String() string {
+	return fmt.Sprintf("%.8f", U88ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a U88) SAdd(b U88) U88 {
+	return U88(clamp(int32(a)+int32(b), int32(MinU88), int32(MaxU88)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a U88) SSub(b U88) U88 {
+	return U88(clamp(int32(a)-int32(b), int32(MinU88), int32(MaxU88)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a U88) SMul(b U88) U88 {
+	return U88(clamp((int64(a)*int64(b))>>8, int64(MinU88), int64(MaxU88)))
+}
+
+// S17 converts a U88 to an S17, rounding down and saturating if it's out
+// of range.
+func (a U88) S17() S17 {
+	return S17(clamp(int64(a)>>1, int64(MinS17), int64(MaxS17)))
+}
+
+// U88 converts an S17 to a U88, rounding down and saturating if it's out
+// of range.
+func (a S17) U88() U88 {
+	return U88(clamp(int64(a)<<1, int64(MinU88), int64(MaxU88)))
+}
+
+func U88ToFloat[T constraints.Float](a U88) T {
+	var scale = 1.0 / T(1<<8)
+	return T(a) * scale
+}
+
+// U88FromFloat converts a float into a U88, clamping to the maximum or
+// minimum values.
+func U88FromFloat[T constraints.Float](f T) U88 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 8)
+	return U88(clamp(v, float64(MinU88), float64(MaxU88)))
+}
+
+// U016 is an unsigned 16 bit fixed point number with 0 integer bits
+// and 16 fractional bits, covering 0 to 0.9999847412109375.
+// It suits phases, which wrap around at 1.
+type U016 uint16
+
+const (
+	// MaxU016 is the highest U016: 0.9999847412109375.
+	MaxU016 U016 = 0xFFFF
+	// MinU016 is the lowest U016: 0.
+	MinU016 U016 = 0
+)
+
+func (a U016) String() string {
+	return fmt.Sprintf("%.16f", U016ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a U016) SAdd(b U016) U016 {
+	return U016(clamp(int32(a)+int32(b), int32(MinU016), int32(MaxU016)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a U016) SSub(b U016) U016 {
+	return U016(clamp(int32(a)-int32(b), int32(MinU016), int32(MaxU016)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a U016) SMul(b U016) U016 {
+	return U016(clamp((int64(a)*int64(b))>>16, int64(MinU016), int64(MaxU016)))
+}
+
+// S17 converts a U016 to an S17, rounding down and saturating if it's out
+// of range.
+func (a U016) S17() S17 {
+	return S17(clamp(int64(a)>>9, int64(MinS17), int64(MaxS17)))
+}
+
+// U016 converts an S17 to a U016, rounding down and saturating if it's out
+// of range.
+func (a S17) U016() U016 {
+	return U016(clamp(int64(a)<<9, int64(MinU016), int64(MaxU016)))
+}
+
+func U016ToFloat[T constraints.Float](a U016) T {
+	var scale = 1.0 / T(1<<16)
+	return T(a) * scale
+}
+
+// U016FromFloat converts a float into a U016, clamping to the maximum or
+// minimum values.
+func U016FromFloat[T constraints.Float](f T) U016 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 16)
+	return U016(clamp(v, float64(MinU016), float64(MaxU016)))
+}
+
+// S131 is a signed (two's complement) 32 bit fixed point number with 1 integer bits
+// and 31 fractional bits, covering -1 to 0.9999999995343387.
+type S131 int32
+
+const (
+	// MaxS131 is the highest S131: 0.9999999995343387.
+	MaxS131 S131 = 0x7FFFFFFF
+	// MinS131 is the lowest S131: -1.
+	MinS131 S131 = -0x80000000
+)
+
+func (a S131) String() string {
+	return fmt.Sprintf("%.31f", S131ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a S131) SAdd(b S131) S131 {
+	return S131(clamp(int64(a)+int64(b), int64(MinS131), int64(MaxS131)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a S131) SSub(b S131) S131 {
+	return S131(clamp(int64(a)-int64(b), int64(MinS131), int64(MaxS131)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a S131) SMul(b S131) S131 {
+	return S131(clamp((int64(a)*int64(b))>>31, int64(MinS131), int64(MaxS131)))
+}
+
+// S17 converts a S131 to an S17, rounding down and saturating if it's out
+// of range.
+func (a S131) S17() S17 {
+	return S17(clamp(int64(a)>>24, int64(MinS17), int64(MaxS17)))
+}
+
+// S131 converts an S17 to a S131, rounding down and saturating if it's out
+// of range.
+func (a S17) S131() S131 {
+	return S131(clamp(int64(a)<<24, int64(MinS131), int64(MaxS131)))
+}
+
+func S131ToFloat[T constraints.Float](a S131) T {
+	var scale = 1.0 / T(1<<31)
+	return T(a) * scale
+}
+
+// S131FromFloat converts a float into a S131, clamping to the maximum or
+// minimum values.
+func S131FromFloat[T constraints.Float](f T) S131 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 31)
+	return S131(clamp(v, float64(MinS131), float64(MaxS131)))
+}
+
+// U1616 is an unsigned 32 bit fixed point number with 16 integer bits
+// and 16 fractional bits, covering 0 to 65535.99998474121.
+// It suits frequencies and other large values that need a fractional part.
+type U1616 uint32
+
+const (
+	// MaxU1616 is the highest U1616: 65535.99998474121.
+	MaxU1616 U1616 = 0xFFFFFFFF
+	// MinU1616 is the lowest U1616: 0.
+	MinU1616 U1616 = 0
+)
+
+func (a U1616) String() string {
+	return fmt.Sprintf("%.16f", U1616ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a U1616) SAdd(b U1616) U1616 {
+	return U1616(clamp(int64(a)+int64(b), int64(MinU1616), int64(MaxU1616)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a U1616) SSub(b U1616) U1616 {
+	return U1616(clamp(int64(a)-int64(b), int64(MinU1616), int64(MaxU1616)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a U1616) SMul(b U1616) U1616 {
+	return U1616(clamp((uint64(a)*uint64(b))>>16, uint64(MinU1616), uint64(MaxU1616)))
+}
+
+// S17 converts a U1616 to an S17, rounding down and saturating if it's out
+// of range.
+func (a U1616) S17() S17 {
+	return S17(clamp(int64(a)>>9, int64(MinS17), int64(MaxS17)))
+}
+
+// U1616 converts an S17 to a U1616, rounding down and saturating if it's out
+// of range.
+func (a S17) U1616() U1616 {
+	return U1616(clamp(int64(a)<<9, int64(MinU1616), int64(MaxU1616)))
+}
+
+func U1616ToFloat[T constraints.Float](a U1616) T {
+	var scale = 1.0 / T(1<<16)
+	return T(a) * scale
+}
+
+// U1616FromFloat converts a float into a U1616, clamping to the maximum or
+// minimum values.
+func U1616FromFloat[T constraints.Float](f T) U1616 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 16)
+	return U1616(clamp(v, float64(MinU1616), float64(MaxU1616)))
+}
+
+// S1616 is a signed (two's complement) 32 bit fixed point number with 16 integer bits
+// and 16 fractional bits, covering -32768 to 32767.99998474121.
+// It suits logarithms and other large values that can be negative.
+type S1616 int32
+
+const (
+	// MaxS1616 is the highest S1616: 32767.99998474121.
+	MaxS1616 S1616 = 0x7FFFFFFF
+	// MinS1616 is the lowest S1616: -32768.
+	MinS1616 S1616 = -0x80000000
+)
+
+func (a S1616) String() string {
+	return fmt.Sprintf("%.16f", S1616ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a S1616) SAdd(b S1616) S1616 {
+	return S1616(clamp(int64(a)+int64(b), int64(MinS1616), int64(MaxS1616)))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a S1616) SSub(b S1616) S1616 {
+	return S1616(clamp(int64(a)-int64(b), int64(MinS1616), int64(MaxS1616)))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a S1616) SMul(b S1616) S1616 {
+	return S1616(clamp((int64(a)*int64(b))>>16, int64(MinS1616), int64(MaxS1616)))
+}
+
+// S17 converts a S1616 to an S17, rounding down and saturating if it's out
+// of range.
+func (a S1616) S17() S17 {
+	return S17(clamp(int64(a)>>9, int64(MinS17), int64(MaxS17)))
+}
+
+// S1616 converts an S17 to a S1616, rounding down and saturating if it's out
+// of range.
+func (a S17) S1616() S1616 {
+	return S1616(clamp(int64(a)<<9, int64(MinS1616), int64(MaxS1616)))
+}
+
+func S1616ToFloat[T constraints.Float](a S1616) T {
+	var scale = 1.0 / T(1<<16)
+	return T(a) * scale
+}
+
+// S1616FromFloat converts a float into a S1616, clamping to the maximum or
+// minimum values.
+func S1616FromFloat[T constraints.Float](f T) S1616 {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << 16)
+	return S1616(clamp(v, float64(MinS1616), float64(MaxS1616)))
+}
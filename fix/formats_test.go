@@ -0,0 +1,93 @@
+package fix
+
+import (
+	"math"
+	"testing"
+)
+
+// format is what the generated formats have in common.
+type format[F any] interface {
+	~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32
+	SAdd(F) F
+	SSub(F) F
+	SMul(F) F
+	S17() S17
+}
+
+// checkFormat tests a format against float arithmetic.
+func checkFormat[F format[F]](t *testing.T, name string, lo, hi F, toFloat func(F) float64, fromFloat func(float64) F, fromS17 func(S17) F) {
+	t.Helper()
+	if got := fromFloat(math.Inf(1)); got != hi {
+		t.Errorf("%s: FromFloat(+Inf) = %v, want: %v", name, got, hi)
+	}
+	if got := fromFloat(math.Inf(-1)); got != lo {
+		t.Errorf("%s: FromFloat(-Inf) = %v, want: %v", name, got, lo)
+	}
+	// Some values spread across the range, including both ends.
+	const steps = 50
+	var vs []F
+	for i := 0; i <= steps; i++ {
+		f := toFloat(lo) + (toFloat(hi)-toFloat(lo))*float64(i)/steps
+		vs = append(vs, fromFloat(f))
+	}
+	lsb := math.Abs(toFloat(lo+1) - toFloat(lo))
+	within := func(got F, want float64) bool {
+		want = min(max(want, toFloat(lo)), toFloat(hi))
+		return math.Abs(toFloat(got)-want) < lsb
+	}
+	for _, a := range vs {
+		if got := fromFloat(toFloat(a)); got != a {
+			t.Errorf("%s: FromFloat(ToFloat(%v)) = %v", name, a, got)
+		}
+		for _, b := range vs {
+			if got := a.SAdd(b); !within(got, toFloat(a)+toFloat(b)) {
+				t.Errorf("%s: %v SAdd %v = %v", name, a, b, got)
+			}
+			if got := a.SSub(b); !within(got, toFloat(a)-toFloat(b)) {
+				t.Errorf("%s: %v SSub %v = %v", name, a, b, got)
+			}
+			if got := a.SMul(b); !within(got, toFloat(a)*toFloat(b)) {
+				t.Errorf("%s: %v SMul %v = %v", name, a, b, got)
+			}
+		}
+		if got, want := Float[float64](a.S17()), min(max(toFloat(a), -1), Float[float64](MaxS17)); math.Abs(got-want) >= 1.0/128 {
+			t.Errorf("%s: %v.S17() = %v", name, a, a.S17())
+		}
+	}
+	for i := int(MinS17); i <= int(MaxS17); i++ {
+		s := S17(i)
+		if got := fromS17(s); !within(got, Float[float64](s)) {
+			t.Errorf("%s: from S17 %v = %v", name, s, got)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	checkFormat(t, "U62", MinU62, MaxU62, U62ToFloat[float64], U62FromFloat[float64], S17.U62)
+	checkFormat(t, "U08", MinU08, MaxU08, U08ToFloat[float64], U08FromFloat[float64], S17.U08)
+	checkFormat(t, "S115", MinS115, MaxS115, S115ToFloat[float64], S115FromFloat[float64], S17.S115)
+	checkFormat(t, "U88", MinU88, MaxU88, U88ToFloat[float64], U88FromFloat[float64], S17.U88)
+	checkFormat(t, "U016", MinU016, MaxU016, U016ToFloat[float64], U016FromFloat[float64], S17.U016)
+	checkFormat(t, "S131", MinS131, MaxS131, S131ToFloat[float64], S131FromFloat[float64], S17.S131)
+	checkFormat(t, "U1616", MinU1616, MaxU1616, U1616ToFloat[float64], U1616FromFloat[float64], S17.U1616)
+	checkFormat(t, "S1616", MinS1616, MaxS1616, S1616ToFloat[float64], S1616FromFloat[float64], S17.S1616)
+}
+
+func TestFormatsFromFloat32(t *testing.T) {
+	// float32 rounds the top of the 32 bit formats up, which mustn't wrap.
+	if got := S131FromFloat(float32(1)); got != MaxS131 {
+		t.Errorf("S131FromFloat(1) = %v, want: %v", got, MaxS131)
+	}
+	if got := U1616FromFloat(float32(65536)); got != MaxU1616 {
+		t.Errorf("U1616FromFloat(65536) = %v, want: %v", got, MaxU1616)
+	}
+}
+
+func TestS17Bits(t *testing.T) {
+	for i := int(MinU62); i <= int(MaxU62); i++ {
+		u := U62(i)
+		if got := U62FromS17Bits(u.S17Bits()); got != u {
+			t.Errorf("U62FromS17Bits(%v.S17Bits()) = %v", u, got)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Command gen writes the fixed point formats in package fix, so that they
+// all behave the same way. Add new formats to the formats list and run go
+// generate in package fix.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strconv"
+	"text/template"
+)
+
+// spec describes a format.
+type spec struct {
+	Signed    bool
+	Int, Frac int
+	// Doc is an optional extra sentence about what it's for.
+	Doc string
+}
+
+var formats = []spec{
+	{false, 6, 2, "It holds midi notes for package osc."},
+	{false, 0, 8, "It suits gains and other things between 0 and 1."},
+	{true, 1, 15, "It covers the same range as S17 with a lot more precision."},
+	{false, 8, 8, "It holds midi notes with much finer tuning than U62."},
+	{false, 0, 16, "It suits phases, which wrap around at 1."},
+	{true, 1, 31, ""},
+	{false, 16, 16, "It suits frequencies and other large values that need a fractional part."},
+	{true, 16, 16, "It suits logarithms and other large values that can be negative."},
+}
+
+func (s spec) Bits() int { return s.Int + s.Frac }
+
+func (s spec) Name() string {
+	p := "U"
+	if s.Signed {
+		p = "S"
+	}
+	return fmt.Sprintf("%s%d%d", p, s.Int, s.Frac)
+}
+
+// Base is the underlying integer type.
+func (s spec) Base() string {
+	if s.Signed {
+		return fmt.Sprintf("int%d", s.Bits())
+	}
+	return fmt.Sprintf("uint%d", s.Bits())
+}
+
+// Wide is big enough for a sum or difference of two values.
+func (s spec) Wide() string {
+	if s.Bits() == 32 {
+		return "int64"
+	}
+	return "int32"
+}
+
+// MulWide is big enough for a product of two values.
+func (s spec) MulWide() string {
+	switch {
+	case s.Bits() == 8 || s.Bits() == 16 && s.Signed:
+		return "int32"
+	case s.Bits() == 32 && !s.Signed:
+		return "uint64"
+	}
+	return "int64"
+}
+
+func (s spec) Max() string {
+	if s.Signed {
+		return fmt.Sprintf("0x%X", uint64(1)<<(s.Bits()-1)-1)
+	}
+	return fmt.Sprintf("0x%X", uint64(1)<<s.Bits()-1)
+}
+
+func (s spec) Min() string {
+	if s.Signed {
+		return fmt.Sprintf("-0x%X", uint64(1)<<(s.Bits()-1))
+	}
+	return "0"
+}
+
+func (s spec) float(v string) string {
+	i, err := strconv.ParseInt(v, 0, 64)
+	if err != nil {
+		u, _ := strconv.ParseUint(v, 0, 64)
+		i = int64(u)
+	}
+	return strconv.FormatFloat(float64(i)/float64(uint64(1)<<s.Frac), 'f', -1, 64)
+}
+
+func (s spec) MaxFloat() string { return s.float(s.Max()) }
+func (s spec) MinFloat() string { return s.float(s.Min()) }
+
+// ToS17 shifts an int64 from this format's fractional bits to S17's.
+func (s spec) ToS17() string { return shift(s.Frac - 7) }
+
+// FromS17 shifts an int64 from S17's fractional bits to this format's.
+func (s spec) FromS17() string { return shift(7 - s.Frac) }
+
+func shift(right int) string {
+	switch {
+	case right > 0:
+		return fmt.Sprintf(" >> %d", right)
+	case right < 0:
+		return fmt.Sprintf(" << %d", -right)
+	}
+	return ""
+}
+
+// Kind describes the format in a doc comment.
+func (s spec) Kind() string {
+	if s.Signed {
+		return "a signed (two's complement)"
+	}
+	return "an unsigned"
+}
+
+var tmpl = template.Must(template.New("formats").Parse(`// Code generated by internal/gen; DO NOT EDIT.
+
+package fix
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/constraints"
+)
+{{range .}}{{$n := .Name}}
+// {{$n}} is {{.Kind}} {{.Bits}} bit fixed point number with {{.Int}} integer bits
+// and {{.Frac}} fractional bits, covering {{.MinFloat}} to {{.MaxFloat}}.{{with .Doc}}
+// {{.}}{{end}}
+type {{$n}} {{.Base}}
+
+const (
+	// Max{{$n}} is the highest {{$n}}: {{.MaxFloat}}.
+	Max{{$n}} {{$n}} = {{.Max}}
+	// Min{{$n}} is the lowest {{$n}}: {{.MinFloat}}.
+	Min{{$n}} {{$n}} = {{.Min}}
+)
+
+func (a {{$n}}) String() string {
+	return fmt.Sprintf("%.{{.Frac}}f", {{$n}}ToFloat[float64](a))
+}
+
+// SAdd is a saturating +, clipping to the minimum or maximum value.
+func (a {{$n}}) SAdd(b {{$n}}) {{$n}} {
+	return {{$n}}(clamp({{.Wide}}(a)+{{.Wide}}(b), {{.Wide}}(Min{{$n}}), {{.Wide}}(Max{{$n}})))
+}
+
+// SSub is a saturating -, clipping to the minimum or maximum value.
+func (a {{$n}}) SSub(b {{$n}}) {{$n}} {
+	return {{$n}}(clamp({{.Wide}}(a)-{{.Wide}}(b), {{.Wide}}(Min{{$n}}), {{.Wide}}(Max{{$n}})))
+}
+
+// SMul multiplies, rounding down and saturating at the maximum or minimum if
+// it overflows.
+func (a {{$n}}) SMul(b {{$n}}) {{$n}} {
+	return {{$n}}(clamp(({{.MulWide}}(a)*{{.MulWide}}(b))>>{{.Frac}}, {{.MulWide}}(Min{{$n}}), {{.MulWide}}(Max{{$n}})))
+}
+
+// S17 converts a {{$n}} to an S17, rounding down and saturating if it's out
+// of range.
+func (a {{$n}}) S17() S17 {
+	return S17(clamp(int64(a){{.ToS17}}, int64(MinS17), int64(MaxS17)))
+}
+
+// {{$n}} converts an S17 to a {{$n}}, rounding down and saturating if it's out
+// of range.
+func (a S17) {{$n}}() {{$n}} {
+	return {{$n}}(clamp(int64(a){{.FromS17}}, int64(Min{{$n}}), int64(Max{{$n}})))
+}
+{{if eq .Bits 8}}
+// S17Bits reinterprets a {{$n}}'s bits as an S17, so that it can be carried
+// through an S17 stream and read back with {{$n}}FromS17Bits. The S17 doesn't
+// mean anything as audio.
+func (a {{$n}}) S17Bits() S17 {
+	return S17(a)
+}
+
+// {{$n}}FromS17Bits is the opposite of S17Bits.
+func {{$n}}FromS17Bits(s S17) {{$n}} {
+	return {{$n}}(s)
+}
+{{end}}
+func {{$n}}ToFloat[T constraints.Float](a {{$n}}) T {
+	var scale = 1.0 / T(1<<{{.Frac}})
+	return T(a) * scale
+}
+
+// {{$n}}FromFloat converts a float into a {{$n}}, clamping to the maximum or
+// minimum values.
+func {{$n}}FromFloat[T constraints.Float](f T) {{$n}} {
+	// Work in float64, float32 can't hold the larger formats' limits.
+	v := float64(f) * (1 << {{.Frac}})
+	return {{$n}}(clamp(v, float64(Min{{$n}}), float64(Max{{$n}})))
+}
+{{end}}`))
+
+func main() {
+	out := flag.String("out", "formats.go", "file to write")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, formats); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package fix
+
+import (
+	"math"
+	"math/bits"
+)
+
+// The functions here use lookup tables with linear interpolation, so they
+// only need integer arithmetic. The tables are built once, at init. Error
+// bounds are in units of the result's least significant bit (LSB) and are
+// checked exhaustively or near enough by the tests.
+
+// lutBits is log2 of the number of segments in each table. With 1024 the
+// interpolation error is below the resolution of the results.
+const lutBits = 10
+
+// lutFrac is the number of fractional bits in the tables.
+const lutFrac = 30
+
+// lut is a table of a function sampled at 2^lutBits + 1 points, with
+// lutFrac fractional bits.
+type lut [1<<lutBits + 1]int64
+
+func makeLUT(f func(x float64) float64) *lut {
+	var t lut
+	for i := range t {
+		t[i] = int64(math.Round(f(float64(i)/(1<<lutBits)) * (1 << lutFrac)))
+	}
+	return &t
+}
+
+// at interpolates the table at x, which has 32 fractional bits covering the
+// whole table.
+func (t *lut) at(x uint32) int64 {
+	i, frac := x>>(32-lutBits), int64(x<<lutBits)
+	a, b := t[i], t[i+1]
+	return a + ((b-a)*frac)>>32
+}
+
+// round shifts v right, rounding to the nearest.
+func round(v int64, shift int) int64 {
+	if shift <= 0 {
+		return v << -shift
+	}
+	return (v + 1<<(shift-1)) >> shift
+}
+
+var (
+	sinLUT   = makeLUT(func(x float64) float64 { return math.Sin(2 * math.Pi * x) })
+	exp2LUT  = makeLUT(math.Exp2)
+	log2LUT  = makeLUT(func(x float64) float64 { return math.Log2(1 + x) })
+	recipLUT = makeLUT(func(x float64) float64 { return 1 / (1 + x) })
+	sqrtLUT  = makeLUT(func(x float64) float64 { return math.Sqrt(1 + x) })
+	sqrt2LUT = makeLUT(func(x float64) float64 { return math.Sqrt(2 * (1 + x)) })
+	// tanh is tabulated from 0 to tanhRange.
+	tanhLUT = makeLUT(func(x float64) float64 { return math.Tanh(x * tanhRange) })
+)
+
+const tanhRange = 8
+
+// Sin returns the sine of a phase, where 1 is a whole cycle. It's within 1
+// LSB of the true value.
+func Sin(phase U016) S115 {
+	v := round(sinLUT.at(uint32(phase)<<16), lutFrac-15)
+	return S115(min(v, int64(MaxS115)))
+}
+
+// Cos returns the cosine of a phase, where 1 is a whole cycle. It's within 1
+// LSB of the true value.
+func Cos(phase U016) S115 {
+	return Sin(phase + 1<<14)
+}
+
+// Exp2 returns 2 to the power of x, saturating above MaxU1616. It's within
+// 1 LSB plus 0.1 parts per million of the true value.
+func Exp2(x S1616) U1616 {
+	i := int64(x) >> 16
+	switch {
+	case i >= 16:
+		return MaxU1616
+	case i < -17:
+		return 0
+	}
+	m := exp2LUT.at(uint32(x) << 16)
+	return U1616(min(round(m, lutFrac-16-int(i)), int64(MaxU1616)))
+}
+
+// normalize splits x into a mantissa between 1 and 2, as 32 fractional bits
+// of the part above 1, and an exponent such that x = m * 2^e.
+func normalize(x U1616) (m uint32, e int) {
+	msb := bits.Len32(uint32(x)) - 1
+	return uint32(x) << (32 - msb), msb - 16
+}
+
+// Log2 returns the base 2 logarithm of x, or MinS1616 for 0. It's within 1
+// LSB of the true value.
+func Log2(x U1616) S1616 {
+	if x == 0 {
+		return MinS1616
+	}
+	m, e := normalize(x)
+	return S1616(int64(e)<<16 + round(log2LUT.at(m), lutFrac-16))
+}
+
+// Recip returns 1/x, saturating at MaxU1616, including for 0. It's within 1
+// LSB of the true value.
+func Recip(x U1616) U1616 {
+	if x == 0 {
+		return MaxU1616
+	}
+	m, e := normalize(x)
+	return U1616(min(round(recipLUT.at(m), lutFrac-16+e), int64(MaxU1616)))
+}
+
+// Sqrt returns the square root of x. It's within 1 LSB of the true value.
+func Sqrt(x U1616) U1616 {
+	if x == 0 {
+		return 0
+	}
+	m, e := normalize(x)
+	t := sqrtLUT
+	if e&1 != 0 {
+		t = sqrt2LUT
+	}
+	// e>>1 rounds down, which sqrt2LUT makes up for.
+	return U1616(round(t.at(m), lutFrac-16-e>>1))
+}
+
+// Tanh returns the hyperbolic tangent of x, which makes a nice soft clipper.
+// It's within 1 LSB of the true value.
+func Tanh(x S1616) S115 {
+	a := int64(x)
+	if a < 0 {
+		a = -a
+	}
+	var v int64
+	if a >= tanhRange<<16 {
+		v = int64(MaxS115)
+	} else {
+		// Scale a to 32 fractional bits covering the table.
+		v = min(round(tanhLUT.at(uint32(a<<(16-3))), lutFrac-15), int64(MaxS115))
+	}
+	if x < 0 {
+		return S115(-v)
+	}
+	return S115(v)
+}
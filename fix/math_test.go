@@ -0,0 +1,106 @@
+package fix
+
+import (
+	"math"
+	"testing"
+)
+
+// u1616s returns U1616s spread over the whole range, with all the small
+// values where the relative error matters most.
+func u1616s() []U1616 {
+	var out []U1616
+	for x := 0; x < 1<<17; x++ {
+		out = append(out, U1616(x))
+	}
+	for x := uint64(1 << 17); x <= uint64(MaxU1616); x += 4999 {
+		out = append(out, U1616(x))
+	}
+	return append(out, MaxU1616)
+}
+
+// checkErr fails if got is further than lsbs LSBs plus the relative error
+// rel away from want.
+func checkErr(t *testing.T, name string, in any, got, want, lsb, lsbs, rel float64) {
+	t.Helper()
+	if d := math.Abs(got - want); d > lsbs*lsb+rel*math.Abs(want) {
+		t.Fatalf("%s(%v) = %v, want: %v (off by %.2f LSBs)", name, in, got, want, d/lsb)
+	}
+}
+
+func TestSinCos(t *testing.T) {
+	for p := 0; p < 1<<16; p++ {
+		x := 2 * math.Pi * float64(p) / (1 << 16)
+		checkErr(t, "Sin", U016(p), S115ToFloat[float64](Sin(U016(p))), math.Sin(x), 1.0/(1<<15), 1, 0)
+		checkErr(t, "Cos", U016(p), S115ToFloat[float64](Cos(U016(p))), math.Cos(x), 1.0/(1<<15), 1, 0)
+	}
+}
+
+func TestExp2(t *testing.T) {
+	max := U1616ToFloat[float64](MaxU1616)
+	for x := int64(MinS1616); x <= int64(MaxS1616); x += 997 {
+		want := math.Min(math.Exp2(S1616ToFloat[float64](S1616(x))), max)
+		checkErr(t, "Exp2", S1616(x), U1616ToFloat[float64](Exp2(S1616(x))), want, 1.0/(1<<16), 1, 1e-7)
+	}
+	for _, c := range []struct {
+		x    S1616
+		want U1616
+	}{
+		{0, 1 << 16},
+		{1 << 16, 2 << 16},
+		{-1 << 16, 1 << 15},
+		{12 << 16, 4096 << 16},
+		{16 << 16, MaxU1616},
+		{MinS1616, 0},
+	} {
+		if got := Exp2(c.x); got != c.want {
+			t.Errorf("Exp2(%v) = %v, want: %v", c.x, got, c.want)
+		}
+	}
+}
+
+func TestLog2(t *testing.T) {
+	for _, x := range u1616s()[1:] {
+		want := math.Log2(U1616ToFloat[float64](x))
+		checkErr(t, "Log2", x, S1616ToFloat[float64](Log2(x)), want, 1.0/(1<<16), 1, 0)
+	}
+	if got := Log2(0); got != MinS1616 {
+		t.Errorf("Log2(0) = %v, want: %v", got, MinS1616)
+	}
+}
+
+func TestRecip(t *testing.T) {
+	max := U1616ToFloat[float64](MaxU1616)
+	for _, x := range u1616s()[1:] {
+		want := math.Min(1/U1616ToFloat[float64](x), max)
+		checkErr(t, "Recip", x, U1616ToFloat[float64](Recip(x)), want, 1.0/(1<<16), 1, 0)
+	}
+	if got := Recip(0); got != MaxU1616 {
+		t.Errorf("Recip(0) = %v, want: %v", got, MaxU1616)
+	}
+}
+
+func TestSqrt(t *testing.T) {
+	for _, x := range u1616s() {
+		want := math.Sqrt(U1616ToFloat[float64](x))
+		checkErr(t, "Sqrt", x, U1616ToFloat[float64](Sqrt(x)), want, 1.0/(1<<16), 1, 0)
+	}
+}
+
+func TestTanh(t *testing.T) {
+	for x := int64(-12 << 16); x <= 12<<16; x += 7 {
+		want := math.Tanh(S1616ToFloat[float64](S1616(x)))
+		checkErr(t, "Tanh", S1616(x), S115ToFloat[float64](Tanh(S1616(x))), want, 1.0/(1<<15), 1, 0)
+	}
+	for _, x := range []S1616{MinS1616, MaxS1616} {
+		want := math.Tanh(S1616ToFloat[float64](x))
+		checkErr(t, "Tanh", x, S115ToFloat[float64](Tanh(x)), want, 1.0/(1<<15), 1, 0)
+	}
+}
+
+func BenchmarkExp2(b *testing.B) {
+	var sink U1616
+	for i := range b.N {
+		sink += Exp2(S1616(i))
+	}
+	_ = sink
+}
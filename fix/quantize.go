@@ -0,0 +1,155 @@
+package fix
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// Quantizer converts floats into S17s. Quantizers can carry state from one
+// sample to the next, so use a separate one for each channel.
+type Quantizer interface {
+	Quantize(f float32) S17
+}
+
+// Quantization picks a kind of Quantizer. At 7 fractional bits the choice
+// is very audible.
+type Quantization int
+
+const (
+	// Truncate rounds towards zero, like FromFloat. The error follows the
+	// signal around, so it sounds like distortion.
+	Truncate Quantization = iota
+	// Round rounds to the nearest S17, which halves the error but it
+	// still sounds like distortion.
+	Round
+	// Dither adds triangular (TPDF) noise of up to 1 LSB before rounding,
+	// which turns the distortion into a steady hiss.
+	Dither
+	// NoiseShape is Dither with first order error feedback, moving the
+	// hiss up towards high frequencies, where it's less noticeable.
+	NoiseShape
+)
+
+var quantizationNames = []string{"truncate", "round", "dither", "noiseshape"}
+
+func (q Quantization) String() string {
+	if q < 0 || int(q) >= len(quantizationNames) {
+		return fmt.Sprintf("Quantization(%d)", int(q))
+	}
+	return quantizationNames[q]
+}
+
+// ParseQuantization is the opposite of Quantization.String.
+func ParseQuantization(s string) (Quantization, error) {
+	for i, n := range quantizationNames {
+		if n == s {
+			return Quantization(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown quantization %q, want one of %v", s, quantizationNames)
+}
+
+// New returns a new Quantizer of this kind.
+func (q Quantization) New() Quantizer {
+	switch q {
+	case Round:
+		return rounder{}
+	case Dither:
+		return &ditherer{rng: newRNG()}
+	case NoiseShape:
+		return NewNoiseShaper(true, 1)
+	}
+	return truncator{}
+}
+
+type truncator struct{}
+
+func (truncator) Quantize(f float32) S17 { return FromFloat(f) }
+
+type rounder struct{}
+
+func (rounder) Quantize(f float32) S17 { return roundLSBs(f * (1 << 7)) }
+
+// roundLSBs rounds a float counting in units of S17's smallest step,
+// clamping to the S17 range.
+func roundLSBs(v float32) S17 {
+	r := math.Floor(float64(v) + 0.5)
+	return S17(min(max(r, float64(MinS17)), float64(MaxS17)))
+}
+
+type ditherer struct {
+	rng rng
+}
+
+func (d *ditherer) Quantize(f float32) S17 {
+	return roundLSBs(f*(1<<7) + d.rng.tpdf())
+}
+
+// NoiseShaper is a Quantizer that feeds its error back through a filter so
+// that the noise it adds ends up where the filter puts it. With coefficients
+// c the noise is filtered by 1 - c[0]z⁻¹ - c[1]z⁻² - ..., so a single 1 is
+// a first order high pass.
+type NoiseShaper struct {
+	coeffs []float32
+	errs   []float32 // most recent first
+	dither bool
+	rng    rng
+}
+
+// NewNoiseShaper returns a NoiseShaper with the given error filter,
+// optionally dithering too.
+func NewNoiseShaper(dither bool, coeffs ...float32) *NoiseShaper {
+	return &NoiseShaper{
+		coeffs: coeffs,
+		errs:   make([]float32, len(coeffs)),
+		dither: dither,
+		rng:    newRNG(),
+	}
+}
+
+func (n *NoiseShaper) Quantize(f float32) S17 {
+	v := f * (1 << 7)
+	for i, c := range n.coeffs {
+		v -= c * n.errs[i]
+	}
+	q := v
+	if n.dither {
+		q += n.rng.tpdf()
+	}
+	out := roundLSBs(q)
+	if len(n.errs) > 0 {
+		// The error is huge when clipping, keeping it small stops
+		// the feedback from running away.
+		copy(n.errs[1:], n.errs)
+		n.errs[0] = min(max(float32(out)-v, -2), 2)
+	}
+	return out
+}
+
+// rng is a xorshift random number generator, which is plenty for dither.
+type rng uint32
+
+var seeds atomic.Uint32
+
+// newRNG returns an rng with a different seed each time, so that channels
+// get independent noise.
+func newRNG() rng {
+	// Spread the seeds out a bit, they can't be 0.
+	return rng(seeds.Add(1)*0x9E3779B9 | 1)
+}
+
+func (r *rng) next() uint32 {
+	x := uint32(*r)
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	*r = rng(x)
+	return x
+}
+
+// tpdf returns noise with a triangular distribution between -1 and 1.
+func (r *rng) tpdf() float32 {
+	const scale = 1.0 / (1 << 32)
+	return float32(r.next())*scale - float32(r.next())*scale
+}
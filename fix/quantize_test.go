@@ -0,0 +1,70 @@
+package fix
+
+import "testing"
+
+func TestQuantizeTruncateRound(t *testing.T) {
+	tq, rq := Truncate.New(), Round.New()
+	for i := -1100; i <= 1100; i++ {
+		f := float32(i) / 1000
+		if got, want := tq.Quantize(f), FromFloat(f); got != want {
+			t.Errorf("truncate %v = %v, want: %v", f, got, want)
+		}
+		got := rq.Quantize(f)
+		want := min(max(f, Float[float32](MinS17)), Float[float32](MaxS17))
+		if err := Float[float32](got) - want; err > 0.5/128 || err < -0.5/128 {
+			t.Errorf("round %v = %v, error %v is more than half an LSB", f, got, err)
+		}
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	// A constant between two S17s, dither should make it come out right
+	// on average but rounding always gets it wrong.
+	const lsbs = 10.3
+	for _, c := range []struct {
+		q       Quantization
+		want    float64
+		maxDiff float64
+	}{
+		{Round, 10, 0},
+		{Dither, lsbs, 0.05},
+		{NoiseShape, lsbs, 0.01},
+	} {
+		q := c.q.New()
+		const n = 10000
+		sum := 0.0
+		for range n {
+			sum += float64(q.Quantize(lsbs / 128))
+		}
+		if mean := sum / n; mean < c.want-c.maxDiff || mean > c.want+c.maxDiff {
+			t.Errorf("%v: mean = %v, want: %v±%v", c.q, mean, c.want, c.maxDiff)
+		}
+	}
+}
+
+func TestNoiseShaperHighPass(t *testing.T) {
+	// With first order shaping the total error is the difference of
+	// consecutive quantizer errors, so summing it up telescopes to
+	// almost nothing: there's no noise at DC.
+	q := NewNoiseShaper(true, 1)
+	total := float32(0)
+	for i := range 10000 {
+		f := float32(i%200-100) / 150
+		total += float32(q.Quantize(f)) - f*128
+	}
+	if total > 4 || total < -4 {
+		t.Errorf("total error = %v, want: within ±4", total)
+	}
+}
+
+func TestParseQuantization(t *testing.T) {
+	for _, q := range []Quantization{Truncate, Round, Dither, NoiseShape} {
+		got, err := ParseQuantization(q.String())
+		if err != nil || got != q {
+			t.Errorf("ParseQuantization(%q) = %v, %v, want: %v", q.String(), got, err, q)
+		}
+	}
+	if _, err := ParseQuantization("nope"); err == nil {
+		t.Error("ParseQuantization(\"nope\") succeeded")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !fxpsat
+
+package fix
+
+// Counting is true if saturations are being counted, which needs the fxpsat
+// build tag. Without it the counting compiles away to nothing.
+const Counting = false
+
+// Saturated records that an operation saturated. It does nothing unless
+// Counting.
+func Saturated() {}
+
+// Saturations returns the number of operations that have saturated so far in
+// the whole process, always 0 unless Counting.
+func Saturations() uint64 { return 0 }
@@ -4,14 +4,22 @@ package fxp
 import (
 	"fmt"
 	"math"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/vec"
 )
 
-// Ticker is something that processes audio.
-// TODO: allow more sample types?
+// Ticker is something that processes audio in fix.S17, the package's native
+// sample type.
 type Ticker interface {
+	TickerOf[fix.S17]
+}
+
+// TickerOf is something that processes audio made of samples of type T.
+type TickerOf[T Sample] interface {
 	// Inputs returns the number of expected input channels.
 	Inputs() int
 	// Outputs returns the number of expected output channels.
@@ -20,47 +28,53 @@ type Ticker interface {
 	// slice is always InChannels, and the first dimension of the output
 	// slice is always OutChannels. Each individual element of both slices
 	// is always the same length. Tickers may overwrite the input buffer.
-	Tick(input, output [][]fix.S17)
+	Tick(input, output [][]T)
 
 	fmt.Stringer
 }
 
 // Splitter is a Ticker that just copies its single input to all of its outputs.
-type Splitter struct {
+type Splitter = SplitterOf[fix.S17]
+
+// SplitterOf is a Splitter for any sample type.
+type SplitterOf[T Sample] struct {
 	outs int
 }
 
 var _ Ticker = Splitter{}
 
-func (s Splitter) Inputs() int    { return 1 }
-func (s Splitter) Outputs() int   { return s.outs }
-func (s Splitter) String() string { return fmt.Sprintf("Splitter%d", s.outs) }
+func (s SplitterOf[T]) Inputs() int    { return 1 }
+func (s SplitterOf[T]) Outputs() int   { return s.outs }
+func (s SplitterOf[T]) String() string { return fmt.Sprintf("Splitter%d", s.outs) }
 
-func (s Splitter) Tick(input, output [][]fix.S17) {
+func (s SplitterOf[T]) Tick(input, output [][]T) {
 	for _, o := range output {
 		copy(o, input[0])
 	}
 }
 
 // Const is a Ticker that always fills its single output with a given value.
-type Const struct {
-	Val fix.S17
+type Const = ConstOf[fix.S17]
+
+// ConstOf is a Const for any sample type.
+type ConstOf[T Sample] struct {
+	Val T
 }
 
 var _ Ticker = Const{}
 
-func (c Const) Inputs() int    { return 0 }
-func (c Const) Outputs() int   { return 1 }
-func (c Const) String() string { return fmt.Sprintf("Const(%v)", c.Val) }
+func (c ConstOf[T]) Inputs() int    { return 0 }
+func (c ConstOf[T]) Outputs() int   { return 1 }
+func (c ConstOf[T]) String() string { return fmt.Sprintf("Const(%v)", c.Val) }
 
-func (c Const) Tick(_, output [][]fix.S17) {
+func (c ConstOf[T]) Tick(_, output [][]T) {
 	for i := range output[0] {
 		output[0][i] = c.Val
 	}
 }
 
 // Scale is a Ticker that multiplies it input by a constant and shifts it by a
-// constant.
+// constant, only saturating the final result.
 type Scale struct {
 	Mul   fix.S17
 	Shift fix.S17
@@ -73,78 +87,150 @@ func (s Scale) Outputs() int   { return 1 }
 func (s Scale) String() string { return fmt.Sprintf("Scale(%v, %v)", s.Mul, s.Shift) }
 
 func (s Scale) Tick(input, output [][]fix.S17) {
-	for i, c := range input[0] {
-		output[0][i] = c.SMul(s.Mul).SAdd(s.Shift)
-	}
+	vec.ScaleOffset(output[0], input[0], s.Mul, s.Shift)
 }
 
 // Chain is a ticker that applies a sequence of Tickers. The inputs and outputs all
 // need to line up.
-type Chain struct {
-	ts              []Ticker
+type Chain = ChainOf[fix.S17]
+
+// ChainOf is a Chain for any sample type.
+type ChainOf[T Sample] struct {
+	ts              []TickerOf[T]
 	inputs, outputs int
-	b1, b2          [][]fix.S17
+	b1, b2          [][]T
+	// ins and outs hold pieces of blocks that are too long for b1 and b2.
+	ins, outs [][]T
 }
 
 var _ Ticker = Chain{}
+var _ Preparer = Chain{}
 
+var _ Validator = Chain{}
+
+// Serially is like NewChain, but panics if the Tickers don't line up.
 func Serially(ts ...Ticker) Chain {
-	if len(ts) == 0 {
-		panic(fmt.Errorf("empty chain"))
+	return SeriallyOf(generic(ts)...)
+}
+
+// SeriallyOf is Serially for any sample type.
+func SeriallyOf[T Sample](ts ...TickerOf[T]) ChainOf[T] {
+	c, err := NewChainOf(ts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewChain returns a Chain that runs the provided Tickers in order, or an
+// error if there aren't any or if the outputs of one don't match the inputs
+// of the next.
+func NewChain(ts ...Ticker) (Chain, error) {
+	return NewChainOf(generic(ts)...)
+}
+
+// NewChainOf is NewChain for any sample type.
+func NewChainOf[T Sample](ts ...TickerOf[T]) (ChainOf[T], error) {
+	if err := checkChain(ts); err != nil {
+		return ChainOf[T]{}, err
 	}
 	maxChans := ts[0].Inputs()
 	for i := 1; i < len(ts); i++ {
-		if ts[i-1].Outputs() != ts[i].Inputs() {
-			panic(fmt.Errorf(
-				"outputs/inputs mismatch:\n%v (%d outputs)\n->\n%v (%d inputs)",
-				ts[i-1], ts[i-1].Outputs(), ts[i], ts[i].Inputs()))
-		}
 		maxChans = max(ts[i-1].Outputs(), maxChans)
 		maxChans = max(ts[i].Inputs(), maxChans)
 	}
 	maxChans = max(ts[len(ts)-1].Outputs(), maxChans)
-	b1 := make([][]fix.S17, maxChans)
-	for i := range b1 {
-		b1[i] = make([]fix.S17, 4096)
-	}
-	b2 := make([][]fix.S17, maxChans)
-	for i := range b2 {
-		b2[i] = make([]fix.S17, 4096)
-	}
-	return Chain{
+	c := ChainOf[T]{
 		ts:      ts,
 		inputs:  ts[0].Inputs(),
 		outputs: ts[len(ts)-1].Outputs(),
-		b1:      b1,
-		b2:      b2,
+		b1:      make([][]T, maxChans),
+		b2:      make([][]T, maxChans),
+		ins:     make([][]T, ts[0].Inputs()),
+		outs:    make([][]T, ts[len(ts)-1].Outputs()),
 	}
+	c.alloc(DefaultMaxBlock)
+	return c, nil
 }
 
-func (c Chain) Inputs() int    { return c.inputs }
-func (c Chain) Outputs() int   { return c.outputs }
-func (c Chain) String() string { return fmt.Sprintf("Chain(%v)", c.ts) }
+// checkChain makes sure ts can be run in sequence.
+func checkChain[T Sample](ts []TickerOf[T]) error {
+	if len(ts) == 0 {
+		return &PathError{Path: []string{"Chain"}, Err: fmt.Errorf("empty chain")}
+	}
+	for i := 1; i < len(ts); i++ {
+		if ts[i-1].Outputs() != ts[i].Inputs() {
+			return &PathError{
+				Path: []string{fmt.Sprintf("Chain[%d]", i)},
+				Err: fmt.Errorf(
+					"outputs/inputs mismatch:\n%v (%d outputs)\n->\n%v (%d inputs)",
+					ts[i-1], ts[i-1].Outputs(), ts[i], ts[i].Inputs()),
+			}
+		}
+	}
+	return nil
+}
+
+// alloc makes new scratch buffers. The outer slices are shared between copies
+// of the Chain, so it doesn't need a pointer receiver.
+func (c ChainOf[T]) alloc(maxBlock int) {
+	for i := range c.b1 {
+		c.b1[i] = make([]T, maxBlock)
+		c.b2[i] = make([]T, maxBlock)
+	}
+}
+
+func (c ChainOf[T]) maxBlock() int {
+	if len(c.b1) == 0 {
+		// No channels anywhere, so nothing to split up.
+		return math.MaxInt
+	}
+	return cap(c.b1[0])
+}
+
+func (c ChainOf[T]) Inputs() int    { return c.inputs }
+func (c ChainOf[T]) Outputs() int   { return c.outputs }
+func (c ChainOf[T]) String() string { return fmt.Sprintf("Chain(%v)", c.ts) }
 
-func (c Chain) Tick(input, output [][]fix.S17) {
-	// TODO: we could certainly skip some copies, but also that gets messy.
-	in, out := c.b1, c.b2
-	for i := range input {
-		copy(in[i], input[i])
-		in[i] = in[i][:len(input[i])]
+func (c ChainOf[T]) Validate() error {
+	if err := checkChain(c.ts); err != nil {
+		return err
 	}
-	for i := range output {
-		out[i] = out[i][:len(output[i])]
-		for j := range out[i] {
-			out[i][j] = 0
+	for i, t := range c.ts {
+		if err := validateChild(t, "Chain", i); err != nil {
+			return err
 		}
 	}
-	in = in[:len(input)]
+	return nil
+}
+
+func (c ChainOf[T]) Prepare(maxBlock int) {
+	c.alloc(maxBlock)
 	for _, t := range c.ts {
-		out = out[:t.Outputs()]
-		t.Tick(in, out)
-		in, out = out, in
+		Prepare(t, maxBlock)
 	}
-	for i := range out {
-		copy(output[i], in[i])
+}
+
+func (c ChainOf[T]) Tick(input, output [][]T) {
+	split(c.maxBlock(), input, output, c.ins, c.outs, c.tick)
+}
+
+func (c ChainOf[T]) tick(input, output [][]T) {
+	// The first Ticker reads straight from input and the last writes
+	// straight to output, so the only buffers are between Tickers.
+	n := blockLen(input, output)
+	in, bufs := input, [2][][]T{c.b1, c.b2}
+	for i, t := range c.ts {
+		out := output
+		if i < len(c.ts)-1 {
+			out = bufs[i%2][:t.Outputs()]
+			for j := range out {
+				out[j] = out[j][:n]
+				clear(out[j])
+			}
+		}
+		t.Tick(in, out)
+		in = out
 	}
 }
 
@@ -168,45 +254,105 @@ func Sum(n int) Mixer {
 	return Mixer{Gains: gs}
 }
 
+var _ Validator = Mixer{}
+
 func (m Mixer) Inputs() int    { return len(m.Gains) }
 func (m Mixer) Outputs() int   { return 1 }
 func (m Mixer) String() string { return "Mixer" }
 
+func (m Mixer) Validate() error {
+	if len(m.Gains) == 0 {
+		return fmt.Errorf("no gains")
+	}
+	return nil
+}
+
 func (m Mixer) Tick(input, output [][]fix.S17) {
-	for i := range input[0] {
+	// Accumulate wide, so only the final mix clips, a chunk at a time so
+	// the accumulators stay on the stack.
+	var acc [256]fix.Acc32
+	out := output[0]
+	for start := 0; start < len(out); start += len(acc) {
+		end := min(start+len(acc), len(out))
+		a := acc[:end-start]
+		clear(a)
 		for j, g := range m.Gains {
-			a := input[j][i].SMul(g)
-			output[0][i] = output[0][i].SAdd(a)
+			vec.MAC(a, input[j][start:end], g)
 		}
+		vec.Narrow(out[start:end], a)
 	}
 }
 
 // Concurrent is a Ticker that joins a group of tickers and runs them at the
-// same time.
-type Concurrent struct {
-	ts              []Ticker
+// same time. By default they are actually run one after the other on the
+// calling goroutine, see Parallel to spread them over multiple cores.
+type Concurrent = ConcurrentOf[fix.S17]
+
+// ConcurrentOf is a Concurrent for any sample type.
+type ConcurrentOf[T Sample] struct {
+	ts              []TickerOf[T]
 	inputs, outputs int
+	pool            *pool[T]
 }
 
 func Concurrently(ts ...Ticker) Concurrent {
+	return ConcurrentlyOf(generic(ts)...)
+}
+
+// ConcurrentlyOf is Concurrently for any sample type.
+func ConcurrentlyOf[T Sample](ts ...TickerOf[T]) ConcurrentOf[T] {
 	ins, outs := 0, 0
 	for _, t := range ts {
 		ins += t.Inputs()
 		outs += t.Outputs()
 	}
-	return Concurrent{
+	return ConcurrentOf[T]{
 		ts:      ts,
 		inputs:  ins,
 		outputs: outs,
 	}
 }
 
+// Parallel returns a Concurrent that runs the same tickers in parallel, using
+// the calling goroutine and up to the given number of additional worker
+// goroutines. If workers is not positive it uses one fewer than GOMAXPROCS.
+// The workers are started straight away and live until Close is called, so
+// Tick doesn't start any goroutines or allocate. The tickers must not share
+// any state. In builds with the fxpsat tag they run one at a time anyway, so
+// that metered Graphs can tell whose saturations are whose. Calling Parallel
+// on a Concurrent that is already parallel stops its workers first, so only
+// the returned Concurrent can be ticked.
+func (c ConcurrentOf[T]) Parallel(workers int) ConcurrentOf[T] {
+	c.Close()
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0) - 1
+	}
+	workers = min(workers, len(c.ts)-1)
+	if workers <= 0 {
+		c.pool = nil
+		return c
+	}
+	c.pool = newPool(c.ts, workers)
+	return c
+}
+
+// Close stops any worker goroutines started by Parallel. The Concurrent
+// must not be ticked afterwards. It's fine to call it more than once, or on
+// more than one copy.
+func (c ConcurrentOf[T]) Close() {
+	if c.pool != nil {
+		c.pool.stop()
+	}
+}
+
 var _ Ticker = Concurrent{}
+var _ Preparer = Concurrent{}
+var _ Validator = Concurrent{}
 
-func (c Concurrent) Inputs() int  { return c.inputs }
-func (c Concurrent) Outputs() int { return c.outputs }
+func (c ConcurrentOf[T]) Inputs() int  { return c.inputs }
+func (c ConcurrentOf[T]) Outputs() int { return c.outputs }
 
-func (c Concurrent) String() string {
+func (c ConcurrentOf[T]) String() string {
 	s := make([]string, len(c.ts))
 	for i, t := range c.ts {
 		s[i] = t.String()
@@ -214,7 +360,28 @@ func (c Concurrent) String() string {
 	return fmt.Sprintf("(%s)", strings.Join(s, ","))
 }
 
-func (c Concurrent) Tick(inputs, outputs [][]fix.S17) {
+func (c ConcurrentOf[T]) Validate() error {
+	for i, t := range c.ts {
+		if err := validateChild(t, "Concurrent", i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c ConcurrentOf[T]) Prepare(maxBlock int) {
+	for _, t := range c.ts {
+		Prepare(t, maxBlock)
+	}
+}
+
+func (c ConcurrentOf[T]) Tick(inputs, outputs [][]T) {
+	// Saturations are counted for the whole process, so they can only be
+	// put down to the right Ticker if they run one at a time.
+	if c.pool != nil && !fix.Counting {
+		c.pool.tick(inputs, outputs)
+		return
+	}
 	in, out := 0, 0
 	for _, t := range c.ts {
 		ni, no := in+t.Inputs(), out+t.Outputs()
@@ -223,18 +390,81 @@ func (c Concurrent) Tick(inputs, outputs [][]fix.S17) {
 	}
 }
 
+// pool is a group of goroutines that run a fixed set of Tickers.
+type pool[T Sample] struct {
+	ts []TickerOf[T]
+	// ins and outs hold the offsets of each ticker's channels.
+	ins, outs []int
+	jobs      chan int
+	stopped   sync.Once
+	wg        sync.WaitGroup
+	// inputs and outputs are the buffers for the current tick, they are
+	// only written before the jobs are sent.
+	inputs, outputs [][]T
+}
+
+func newPool[T Sample](ts []TickerOf[T], workers int) *pool[T] {
+	p := &pool[T]{
+		ts:   ts,
+		ins:  make([]int, len(ts)+1),
+		outs: make([]int, len(ts)+1),
+		jobs: make(chan int, len(ts)),
+	}
+	for i, t := range ts {
+		p.ins[i+1] = p.ins[i] + t.Inputs()
+		p.outs[i+1] = p.outs[i] + t.Outputs()
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range p.jobs {
+				p.run(j)
+				p.wg.Done()
+			}
+		}()
+	}
+	return p
+}
+
+// stop stops the workers, if they haven't been already.
+func (p *pool[T]) stop() {
+	p.stopped.Do(func() { close(p.jobs) })
+}
+
+func (p *pool[T]) run(i int) {
+	p.ts[i].Tick(
+		p.inputs[p.ins[i]:p.ins[i+1]],
+		p.outputs[p.outs[i]:p.outs[i+1]],
+	)
+}
+
+// tick runs all the tickers and waits for them to finish. The first one runs
+// on the calling goroutine.
+func (p *pool[T]) tick(inputs, outputs [][]T) {
+	p.inputs, p.outputs = inputs, outputs
+	p.wg.Add(len(p.ts) - 1)
+	for i := 1; i < len(p.ts); i++ {
+		p.jobs <- i
+	}
+	p.run(0)
+	p.wg.Wait()
+	p.inputs, p.outputs = nil, nil
+}
+
 // Mult copies a single input to the provided number of outputs.
-type Mult struct {
+type Mult = MultOf[fix.S17]
+
+// MultOf is a Mult for any sample type.
+type MultOf[T Sample] struct {
 	N int
 }
 
 var _ Ticker = Mult{}
 
-func (Mult) Inputs() int      { return 1 }
-func (m Mult) Outputs() int   { return m.N }
-func (m Mult) String() string { return fmt.Sprintf("Mult(%d)", m.N) }
+func (MultOf[T]) Inputs() int      { return 1 }
+func (m MultOf[T]) Outputs() int   { return m.N }
+func (m MultOf[T]) String() string { return fmt.Sprintf("Mult(%d)", m.N) }
 
-func (m Mult) Tick(inputs, outputs [][]fix.S17) {
+func (m MultOf[T]) Tick(inputs, outputs [][]T) {
 	for _, o := range outputs {
 		copy(o, inputs[0])
 	}
@@ -248,21 +478,22 @@ func (Amp) Outputs() int   { return 1 }
 func (Amp) String() string { return "Amp" }
 
 func (Amp) Tick(inputs, outputs [][]fix.S17) {
-	for i := range outputs[0] {
-		outputs[0][i] = inputs[0][i].SMul(inputs[1][i])
-	}
+	vec.Mul(outputs[0], inputs[0], inputs[1])
 }
 
 // Noop is a Ticker that just copies its inputs to its outputs.
-type Noop struct {
+type Noop = NoopOf[fix.S17]
+
+// NoopOf is a Noop for any sample type.
+type NoopOf[T Sample] struct {
 	N int
 }
 
-func (n Noop) Inputs() int    { return n.N }
-func (n Noop) Outputs() int   { return n.N }
-func (n Noop) String() string { return fmt.Sprintf("Noop(%d)", n) }
+func (n NoopOf[T]) Inputs() int    { return n.N }
+func (n NoopOf[T]) Outputs() int   { return n.N }
+func (n NoopOf[T]) String() string { return fmt.Sprintf("Noop(%d)", n.N) }
 
-func (n Noop) Tick(inputs, outputs [][]fix.S17) {
+func (n NoopOf[T]) Tick(inputs, outputs [][]T) {
 	for i := range inputs {
 		copy(outputs[i], inputs[i])
 	}
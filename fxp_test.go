@@ -0,0 +1,179 @@
+package fxp
+
+import (
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+func TestConcurrentParallel(t *testing.T) {
+	var ts []Ticker
+	for i := 0; i < 8; i++ {
+		ts = append(ts, Serially(Scale{Mul: fix.S17(i * 16)}, Noop{N: 1}))
+	}
+	seq := Concurrently(ts...)
+	par := Concurrently(ts...).Parallel(3)
+	defer par.Close()
+
+	in := makeBufs(8, 64)
+	for i := range in {
+		for j := range in[i] {
+			in[i][j] = fix.S17(j - 32)
+		}
+	}
+	want, got := makeBufs(8, 64), makeBufs(8, 64)
+	seq.Tick(in, want)
+	for n := 0; n < 10; n++ {
+		par.Tick(in, got)
+		for i := range want {
+			for j := range want[i] {
+				if got[i][j] != want[i][j] {
+					t.Fatalf("tick %d: out[%d][%d] = %v, want: %v", n, i, j, got[i][j], want[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentParallelAllocs(t *testing.T) {
+	c := Concurrently(Scale{Mul: 64}, Scale{Mul: 32}, Scale{Mul: 16}).Parallel(2)
+	defer c.Close()
+	in, out := makeBufs(3, 256), makeBufs(3, 256)
+	if n := testing.AllocsPerRun(100, func() { c.Tick(in, out) }); n != 0 {
+		t.Errorf("Tick allocated %v times per run", n)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	c := Concurrently(Scale{Mul: 64}, Scale{Mul: 32}, Scale{Mul: 16}).Parallel(2)
+	// Parallel again should stop the first workers.
+	again := c.Parallel(2)
+	select {
+	case _, ok := <-c.pool.jobs:
+		if ok {
+			t.Error("got a job from the old pool")
+		}
+	default:
+		t.Error("Parallel didn't stop the old pool")
+	}
+	in, out := makeBufs(3, 16), makeBufs(3, 16)
+	again.Tick(in, out)
+	// Closing twice, and closing a copy, shouldn't panic.
+	again.Close()
+	again.Close()
+	c.Close()
+	Concurrently(Scale{}).Close()
+}
+
+func TestChainLargeBlocks(t *testing.T) {
+	for _, c := range []struct {
+		prepare, block int
+	}{
+		{0, DefaultMaxBlock * 3},
+		{16, 100},
+		{16, 16},
+		{16, 3},
+	} {
+		ch := Serially(Mult{N: 2}, Mixer{Gains: []fix.S17{64, 64}}, Scale{Mul: fix.MaxS17, Shift: 1})
+		if c.prepare != 0 {
+			Prepare(ch, c.prepare)
+		}
+		in, out := makeBufs(1, c.block), makeBufs(1, c.block)
+		for i := range in[0] {
+			in[0][i] = fix.S17(i % 100)
+		}
+		// Tick twice to make sure nothing is left over.
+		ch.Tick(in, out)
+		ch.Tick(in, out)
+		for i, got := range out[0] {
+			mixed := fix.Acc32(0).MAC(in[0][i], 64).MAC(in[0][i], 64).S17()
+			want := mixed.SMul(fix.MaxS17).SAdd(1)
+			if got != want {
+				t.Fatalf("prepare %d, block %d: out[0][%d] = %v, want: %v", c.prepare, c.block, i, got, want)
+			}
+		}
+	}
+}
+
+func TestGraphPrepare(t *testing.T) {
+	g := NewGraph(1, 1)
+	n := g.Add(Serially(Scale{Mul: 64}))
+	for _, e := range [][2]Node{{GraphInputs, n}, {n, GraphOutputs}} {
+		if err := g.Wire(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Prepare(8)
+	in, out := makeBufs(1, 100), makeBufs(1, 100)
+	for i := range in[0] {
+		in[0][i] = fix.S17(i)
+	}
+	g.Tick(in, out)
+	for i, got := range out[0] {
+		if want := fix.S17(i).SMul(64); got != want {
+			t.Fatalf("out[0][%d] = %v, want: %v", i, got, want)
+		}
+	}
+}
+
+func TestMixerHeadroom(t *testing.T) {
+	// The first two inputs clip on their own, but not once the third is
+	// mixed in.
+	m := Mixer{Gains: []fix.S17{fix.MaxS17, fix.MaxS17, fix.MaxS17}}
+	in := [][]fix.S17{{100, -100}, {100, -100}, {-120, 120}}
+	out := makeBufs(1, 2)
+	m.Tick(in, out)
+	for i, want := range []fix.S17{79, -80} {
+		if out[0][i] != want {
+			t.Errorf("out[0][%d] = %v, want: %v", i, out[0][i], want)
+		}
+	}
+}
+
+func TestRequantize(t *testing.T) {
+	in := make([]fix.S17, 256)
+	for i := range in {
+		in[i] = fix.S17(i - 128)
+	}
+	for _, c := range []struct {
+		bits int
+		q    fix.Quantization
+		want func(fix.S17) fix.S17
+	}{
+		{8, fix.Truncate, func(s fix.S17) fix.S17 { return s }},
+		{8, fix.Round, func(s fix.S17) fix.S17 { return s }},
+		// Truncating rounds towards zero.
+		{4, fix.Truncate, func(s fix.S17) fix.S17 {
+			if s < 0 {
+				return -(-s &^ 15)
+			}
+			return s &^ 15
+		}},
+		{1, fix.Truncate, func(s fix.S17) fix.S17 {
+			if s == fix.MinS17 {
+				return s
+			}
+			return 0
+		}},
+		{4, fix.Round, func(s fix.S17) fix.S17 {
+			return fix.S17(min((int(s)+8)&^15, 112))
+		}},
+	} {
+		r := NewRequantize(1, c.bits, c.q)
+		if err := Validate(r); err != nil {
+			t.Fatal(err)
+		}
+		out := makeBufs(1, len(in))
+		r.Tick([][]fix.S17{in}, out)
+		for i, s := range in {
+			if want := c.want(s); out[0][i] != want {
+				t.Errorf("%d bits, %v: %v -> %v, want: %v", c.bits, c.q, s, out[0][i], want)
+			}
+		}
+	}
+	for _, bits := range []int{0, 9} {
+		if err := Validate(NewRequantize(1, bits, fix.Round)); err == nil {
+			t.Errorf("Validate accepted %d bits", bits)
+		}
+	}
+}
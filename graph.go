@@ -2,63 +2,545 @@ package fxp
 
 import (
 	"fmt"
+	"strings"
+	"sync/atomic"
 
 	"github.com/pfcm/fxp/fix"
 )
 
-// Graph is a directed acyclic graph of Tickers. It is itself a Ticker.
-type Graph struct {
-	// TODO: this representation is probably bad, we should ideally
-	// keep them all in a flat list (?)
-	heads           []GraphNode
+// Graph is a directed graph of Tickers. It is itself a Ticker.
+//
+// Any output channel of any node can be connected to any input channel of any
+// other node. An output that feeds several inputs is copied to each of them,
+// several outputs that feed the same input are summed (saturating) and inputs
+// that aren't connected to anything receive silence. Nodes are ticked in
+// topological order, each with its own input and output buffers, so Tickers
+// in a Graph are free to overwrite their inputs.
+//
+// Cycles are allowed: they are broken by delaying some of the connections
+// that form them (the feedback connections) by a fixed number of samples,
+// see SetFeedbackDelay. A Graph with feedback processes audio in blocks no
+// longer than that delay.
+type Graph = GraphOf[fix.S17]
+
+// GraphOf is a Graph for any sample type.
+type GraphOf[T Sample] struct {
 	inputs, outputs int
+	nodes           []*graphNode[T]
+	// outs holds the sources for each of the graph's own outputs.
+	outs [][]edge[T]
+	// fbDelay is the number of samples feedback edges are delayed by.
+	fbDelay int
+	// maxBlock is the largest block the graph will be ticked with without
+	// splitting it up.
+	maxBlock int
+
+	// order is the order to tick the nodes in, nil if the graph has
+	// changed since it was last compiled.
+	order []*graphNode[T]
+	// feedback holds the delay lines of all the feedback edges in the
+	// compiled graph.
+	feedback []*feedback[T]
+	// subIns and subOuts hold slices of the inputs and outputs when the
+	// graph needs to process a block in smaller pieces.
+	subIns, subOuts [][]T
+	// outMeter counts saturation mixing into the graph's outputs, it's nil
+	// unless the graph is metered.
+	outMeter *meter
+	// ops counts the fix operations that saturated while the graph was
+	// ticking, if it's metered.
+	ops atomic.Uint64
+}
+
+// Node identifies a Ticker that has been added to a Graph.
+type Node int
+
+const (
+	// GraphInputs is the pseudo-node whose output channels are the inputs
+	// of the Graph itself.
+	GraphInputs Node = -1
+	// GraphOutputs is the pseudo-node whose input channels are the outputs
+	// of the Graph itself.
+	GraphOutputs Node = -2
+)
+
+// DefaultFeedbackDelay is the delay, in samples, added to feedback
+// connections in a Graph unless it is changed with SetFeedbackDelay.
+const DefaultFeedbackDelay = 64
+
+// port is a single channel of a node.
+type port struct {
+	node    Node
+	channel int
+}
+
+// edge is a connection from a port, if it is part of a cycle it also has a
+// delay line.
+type edge[T Sample] struct {
+	port
+	fb *feedback[T]
+	// mixed is nil unless the graph is metered.
+	mixed *atomic.Uint64
+}
+
+type graphNode[T Sample] struct {
+	TickerOf[T]
+	// srcs holds the sources for each input channel.
+	srcs      [][]edge[T]
+	ins, outs [][]T
+	// meter is nil unless the graph is metered.
+	meter *meter
+}
+
+// feedback is a fixed delay line on a feedback edge. Every block it is read
+// from before the source node is ticked and written to afterwards, so it
+// delays by exactly len(buf) as long as blocks are no longer than that.
+type feedback[T Sample] struct {
+	src     *graphNode[T]
+	channel int
+	buf     []T
+	pos     int
+	// out holds the samples from the last read.
+	out []T
 }
 
-type GraphNode struct {
-	Ticker
-	Children []GraphNode
+// read returns the next n samples from the delay line.
+func (f *feedback[T]) read(n int) []T {
+	out := f.out[:n]
+	c := copy(out, f.buf[f.pos:])
+	copy(out[c:], f.buf)
+	return out
 }
 
-// TODO: only actually works for trees.
-func NewGraph(n GraphNode) *Graph {
-	g := &Graph{
-		heads:  []GraphNode{n},
-		inputs: 1,
+// write stores the source's latest n samples in the delay line, in the same
+// place they were just read from.
+func (f *feedback[T]) write(n int) {
+	src := f.src.outs[f.channel][:n]
+	c := copy(f.buf[f.pos:], src)
+	copy(f.buf, src[c:])
+	f.pos = (f.pos + n) % len(f.buf)
+}
+
+// NewGraph returns an empty Graph with the given number of input and output
+// channels. Add Tickers to it with Add and wire them up with Connect.
+func NewGraph(inputs, outputs int) *Graph {
+	return NewGraphOf[fix.S17](inputs, outputs)
+}
+
+// NewGraphOf is NewGraph for any sample type.
+func NewGraphOf[T Sample](inputs, outputs int) *GraphOf[T] {
+	return &GraphOf[T]{
+		inputs:   inputs,
+		outputs:  outputs,
+		outs:     make([][]edge[T], outputs),
+		fbDelay:  DefaultFeedbackDelay,
+		maxBlock: DefaultMaxBlock,
+	}
+}
+
+// Add adds a Ticker to the graph, returning a Node that can be used to
+// connect it to other nodes. Its inputs are silent until connected.
+func (g *GraphOf[T]) Add(t TickerOf[T]) Node {
+	g.nodes = append(g.nodes, &graphNode[T]{
+		TickerOf: t,
+		srcs:     make([][]edge[T], t.Inputs()),
+		ins:      make([][]T, t.Inputs()),
+		outs:     make([][]T, t.Outputs()),
+	})
+	if g.outMeter != nil {
+		g.nodes[len(g.nodes)-1].meter = newMeter(t.Outputs())
+	}
+	g.order = nil
+	return Node(len(g.nodes) - 1)
+}
+
+// Connect connects output channel out of node from to input channel in of
+// node to. Use GraphInputs and GraphOutputs to connect to the Graph's own
+// inputs and outputs.
+func (g *GraphOf[T]) Connect(from Node, out int, to Node, in int) error {
+	if from == GraphOutputs {
+		return fmt.Errorf("can't connect from the graph outputs")
+	}
+	if to == GraphInputs {
+		return fmt.Errorf("can't connect to the graph inputs")
+	}
+	if n, err := g.numOutputs(from); err != nil {
+		return err
+	} else if out < 0 || out >= n {
+		return fmt.Errorf("%v has %d outputs: can't connect output %d", g.name(from), n, out)
+	}
+	if n, err := g.numInputs(to); err != nil {
+		return err
+	} else if in < 0 || in >= n {
+		return fmt.Errorf("%v has %d inputs: can't connect input %d", g.name(to), n, in)
 	}
-	// We have to traverse the graph to figure out the number of outputs.
-	// This seems a bit silly.
-	outs := 0
-	todo := []GraphNode{n}
-	for len(todo) != 0 {
-		current := todo[len(todo)-1]
-		todo = todo[:len(todo)-1]
-		if len(current.Children) == 0 {
-			outs += current.Outputs()
+	p := edge[T]{port: port{node: from, channel: out}}
+	if g.outMeter != nil {
+		p.mixed = new(atomic.Uint64)
+	}
+	if to == GraphOutputs {
+		g.outs[in] = append(g.outs[in], p)
+	} else {
+		g.nodes[to].srcs[in] = append(g.nodes[to].srcs[in], p)
+	}
+	g.order = nil
+	return nil
+}
+
+// Wire connects every output of from to the corresponding input of to. They
+// must have the same number of channels.
+func (g *GraphOf[T]) Wire(from, to Node) error {
+	outs, err := g.numOutputs(from)
+	if err != nil {
+		return err
+	}
+	ins, err := g.numInputs(to)
+	if err != nil {
+		return err
+	}
+	if outs != ins {
+		return fmt.Errorf("outputs/inputs mismatch:\n%v (%d outputs)\n->\n%v (%d inputs)",
+			g.name(from), outs, g.name(to), ins)
+	}
+	for i := 0; i < outs; i++ {
+		if err := g.Connect(from, i, to, i); err != nil {
+			return err
 		}
-		todo = append(todo, current.Children...)
 	}
-	g.outputs = outs
+	return nil
+}
+
+func (g *GraphOf[T]) numOutputs(n Node) (int, error) {
+	switch {
+	case n == GraphInputs:
+		return g.inputs, nil
+	case n >= 0 && int(n) < len(g.nodes):
+		return g.nodes[n].Outputs(), nil
+	}
+	return 0, fmt.Errorf("no node %d in graph", n)
+}
+
+func (g *GraphOf[T]) numInputs(n Node) (int, error) {
+	switch {
+	case n == GraphOutputs:
+		return g.outputs, nil
+	case n >= 0 && int(n) < len(g.nodes):
+		return g.nodes[n].Inputs(), nil
+	}
+	return 0, fmt.Errorf("no node %d in graph", n)
+}
+
+func (g *GraphOf[T]) name(n Node) string {
+	switch {
+	case n == GraphInputs:
+		return "graph inputs"
+	case n == GraphOutputs:
+		return "graph outputs"
+	case n >= 0 && int(n) < len(g.nodes):
+		return g.nodes[n].String()
+	}
+	return fmt.Sprintf("Node(%d)", n)
+}
 
-	return g
+// SetFeedbackDelay sets the number of samples by which connections that
+// close a cycle are delayed. A graph with feedback is processed in blocks no
+// longer than this, so shorter delays cost more: a delay of 1 gives single
+// sample feedback at the price of ticking every node one sample at a time.
+func (g *GraphOf[T]) SetFeedbackDelay(n int) {
+	g.fbDelay = max(1, n)
+	g.order = nil
+}
+
+// FeedbackLatency returns the delay, in samples, that was added to the
+// graph's feedback connections, or 0 if it doesn't have any.
+func (g *GraphOf[T]) FeedbackLatency() int {
+	if err := g.Compile(); err != nil || len(g.feedback) == 0 {
+		return 0
+	}
+	return g.fbDelay
 }
 
-// Node is a convenience for defining simple subgraphs. The outputs
-// of the first ticker will be connected to the provided children
-// in order. Nested calls will build trees.
-func Node(t Ticker, children ...GraphNode) (GraphNode, error) {
-	if o := t.Outputs(); o != len(children) {
-		return GraphNode{}, fmt.Errorf("%v wants %d outputs: got %v", t, o, children)
+// Prepare sets the largest block the graph expects to be ticked with, blocks
+// larger than this are split up. If the graph is already compiled the buffers
+// are resized straight away, keeping whatever is in the feedback delays, so
+// the next Tick doesn't have to.
+func (g *GraphOf[T]) Prepare(maxBlock int) {
+	g.maxBlock = maxBlock
+	if g.order != nil {
+		g.allocate()
 	}
-	return GraphNode{
-		Ticker:   t,
-		Children: children,
-	}, nil
+}
+
+// blockSize is the largest block the nodes are ticked with.
+func (g *GraphOf[T]) blockSize() int {
+	if len(g.feedback) != 0 {
+		// Feedback only works if we never tick more than the delay
+		// at once.
+		return min(g.maxBlock, g.fbDelay)
+	}
+	return g.maxBlock
+}
+
+// Compile works out the order to tick the nodes in and which connections need
+// to be delayed to break cycles, and allocates buffers for them. It is called
+// by the first Tick after the graph changes, which panics on error, so it's
+// worth calling it up front along with Prepare.
+func (g *GraphOf[T]) Compile() error {
+	if g.order != nil {
+		return nil
+	}
+	// Clear out any feedback from last time, the cycles might be different.
+	for _, n := range g.nodes {
+		for _, srcs := range n.srcs {
+			for i := range srcs {
+				srcs[i].fb = nil
+			}
+		}
+	}
+	g.feedback = nil
+	// Kahn's algorithm, preferring nodes in the order they were added so
+	// the result is stable. If we get stuck there's a cycle, which we
+	// break at the earliest node whose unplaced inputs all come from its
+	// own strongly connected component, by delaying those inputs. There
+	// always is one, and only edges that are part of a cycle get delayed.
+	indegree := make([]int, len(g.nodes))
+	children := make([][]int, len(g.nodes))
+	for i, n := range g.nodes {
+		for _, srcs := range n.srcs {
+			for _, e := range srcs {
+				if e.node == GraphInputs {
+					continue
+				}
+				indegree[i]++
+				children[e.node] = append(children[e.node], i)
+			}
+		}
+	}
+	comp := components(children)
+	var ready []int
+	for i, d := range indegree {
+		if d == 0 {
+			ready = append(ready, i)
+		}
+	}
+	placed := make([]bool, len(g.nodes))
+	order := make([]*graphNode[T], 0, len(g.nodes))
+	for len(order) != len(g.nodes) {
+		if len(ready) == 0 {
+			i := 0
+			for placed[i] || !cycleInputsOnly(g.nodes[i], i, placed, comp) {
+				i++
+			}
+			for _, srcs := range g.nodes[i].srcs {
+				for j, e := range srcs {
+					if e.node == GraphInputs || placed[e.node] {
+						continue
+					}
+					fb := &feedback[T]{
+						src:     g.nodes[e.node],
+						channel: e.channel,
+						buf:     make([]T, g.fbDelay),
+						out:     make([]T, g.fbDelay),
+					}
+					srcs[j].fb = fb
+					g.feedback = append(g.feedback, fb)
+					// Not removing the edge from children
+					// means this will go negative later,
+					// which is fine.
+					indegree[i]--
+				}
+			}
+			ready = append(ready, i)
+		}
+		i := ready[0]
+		ready = ready[1:]
+		placed[i] = true
+		order = append(order, g.nodes[i])
+		for _, c := range children[i] {
+			indegree[c]--
+			if indegree[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	g.order = order
+	g.subIns = make([][]T, g.inputs)
+	g.subOuts = make([][]T, g.outputs)
+	g.allocate()
+	return nil
+}
+
+// allocate makes sure the node buffers fit a block and prepares the nodes for
+// it. Buffers that are already big enough are kept.
+func (g *GraphOf[T]) allocate() {
+	block := g.blockSize()
+	for _, n := range g.nodes {
+		for _, bufs := range [][][]T{n.ins, n.outs} {
+			for i := range bufs {
+				if cap(bufs[i]) < block {
+					bufs[i] = make([]T, block)
+				}
+				bufs[i] = bufs[i][:block]
+			}
+		}
+		Prepare(n.TickerOf, block)
+	}
+}
+
+// cycleInputsOnly reports whether all of node i's inputs that haven't been
+// placed come from its own strongly connected component.
+func cycleInputsOnly[T Sample](n *graphNode[T], i int, placed []bool, comp []int) bool {
+	for _, srcs := range n.srcs {
+		for _, e := range srcs {
+			if e.node != GraphInputs && !placed[e.node] && comp[e.node] != comp[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// components labels each node with its strongly connected component, using
+// Tarjan's algorithm. children lists the nodes each node feeds.
+func components(children [][]int) []int {
+	var (
+		comp    = make([]int, len(children))
+		index   = make([]int, len(children))
+		low     = make([]int, len(children))
+		onStack = make([]bool, len(children))
+		stack   []int
+		next    = 1
+		ncomp   int
+		visit   func(v int)
+	)
+	visit = func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range children[v] {
+			switch {
+			case index[w] == 0:
+				visit(w)
+				low[v] = min(low[v], low[w])
+			case onStack[w]:
+				low[v] = min(low[v], index[w])
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			comp[w] = ncomp
+			if w == v {
+				break
+			}
+		}
+		ncomp++
+	}
+	for v := range children {
+		if index[v] == 0 {
+			visit(v)
+		}
+	}
+	return comp
 }
 
 var _ Ticker = &Graph{}
+var _ Preparer = &Graph{}
+var _ Validator = &Graph{}
+
+func (g *GraphOf[T]) Inputs() int  { return g.inputs }
+func (g *GraphOf[T]) Outputs() int { return g.outputs }
 
-func (g *Graph) Inputs() int    { return g.inputs }
-func (g *Graph) Outputs() int   { return g.outputs }
-func (g *Graph) String() string { return fmt.Sprint(g.heads) }
+func (g *GraphOf[T]) String() string {
+	s := make([]string, len(g.nodes))
+	for i, n := range g.nodes {
+		s[i] = n.String()
+	}
+	return fmt.Sprintf("Graph(%s)", strings.Join(s, ","))
+}
+
+func (g *GraphOf[T]) Validate() error {
+	if err := g.Compile(); err != nil {
+		return err
+	}
+	for i, n := range g.nodes {
+		if err := validateChild(n.TickerOf, "Graph", i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GraphOf[T]) Tick(inputs, outputs [][]T) {
+	if err := g.Compile(); err != nil {
+		panic(err)
+	}
+	if fix.Counting && g.outMeter != nil {
+		before := fix.Saturations()
+		defer func() { g.ops.Add(fix.Saturations() - before) }()
+	}
+	split(g.blockSize(), inputs, outputs, g.subIns, g.subOuts, g.tick)
+}
+
+// tick processes a single block that fits in the node buffers.
+func (g *GraphOf[T]) tick(inputs, outputs [][]T) {
+	n := blockLen(inputs, outputs)
+	if n == 0 {
+		return
+	}
+	for _, node := range g.order {
+		for i, srcs := range node.srcs {
+			node.ins[i] = node.ins[i][:n]
+			g.gather(node.ins[i], srcs, inputs)
+		}
+		for i := range node.outs {
+			node.outs[i] = node.outs[i][:n]
+			clear(node.outs[i])
+		}
+		if node.meter != nil {
+			tickMetered(node)
+			continue
+		}
+		node.Tick(node.ins, node.outs)
+	}
+	for _, fb := range g.feedback {
+		fb.write(n)
+	}
+	for i, srcs := range g.outs {
+		g.gather(outputs[i], srcs, inputs)
+	}
+}
 
-func (g *Graph) Tick(inputs, outputs [][]fix.S17) {}
+// gather fills dst with the sum of the provided sources, counting the samples
+// that saturate on metered edges.
+func (g *GraphOf[T]) gather(dst []T, srcs []edge[T], inputs [][]T) {
+	if len(srcs) == 0 {
+		clear(dst)
+		return
+	}
+	for i, e := range srcs {
+		var src []T
+		switch {
+		case e.fb != nil:
+			src = e.fb.read(len(dst))
+		case e.node == GraphInputs:
+			src = inputs[e.channel]
+		default:
+			src = g.nodes[e.node].outs[e.channel]
+		}
+		if i == 0 {
+			copy(dst, src)
+			continue
+		}
+		if e.mixed != nil {
+			e.mixed.Add(uint64(mixCount(dst, src)))
+			continue
+		}
+		mix(dst, src)
+	}
+}
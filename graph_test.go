@@ -0,0 +1,305 @@
+package fxp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+func makeBufs(chans, n int) [][]fix.S17 {
+	out := make([][]fix.S17, chans)
+	for i := range out {
+		out[i] = make([]fix.S17, n)
+	}
+	return out
+}
+
+func TestGraphFanOut(t *testing.T) {
+	g := NewGraph(0, 3)
+	lfo := g.Add(Const{Val: 64})
+	for i, mul := range []fix.S17{64, 32, -64} {
+		s := g.Add(Scale{Mul: mul})
+		if err := g.Connect(lfo, 0, s, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Connect(s, 0, GraphOutputs, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := makeBufs(3, 16)
+	g.Tick(nil, out)
+	for i, want := range []fix.S17{32, 16, -32} {
+		for j, got := range out[i] {
+			if got != want {
+				t.Fatalf("out[%d][%d] = %v, want: %v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestGraphFanIn(t *testing.T) {
+	g := NewGraph(1, 1)
+	a := g.Add(Const{Val: 10})
+	n := g.Add(Noop{N: 1})
+	for _, from := range []Node{GraphInputs, a} {
+		if err := g.Connect(from, 0, n, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Wire(n, GraphOutputs); err != nil {
+		t.Fatal(err)
+	}
+	in := [][]fix.S17{{0, 5, 120, -20}}
+	out := makeBufs(1, 4)
+	g.Tick(in, out)
+	for i, want := range []fix.S17{10, 15, fix.MaxS17, -10} {
+		if out[0][i] != want {
+			t.Errorf("out[0][%d] = %v, want: %v", i, out[0][i], want)
+		}
+	}
+}
+
+// Nodes should be ticked in dependency order regardless of the order they
+// were added in.
+func TestGraphOrder(t *testing.T) {
+	g := NewGraph(0, 1)
+	amp := g.Add(Amp{})
+	b := g.Add(Scale{Mul: fix.MaxS17})
+	a := g.Add(Const{Val: 64})
+	for _, c := range []struct {
+		from Node
+		out  int
+		to   Node
+		in   int
+	}{
+		{a, 0, b, 0},
+		{a, 0, amp, 0},
+		{b, 0, amp, 1},
+		{amp, 0, GraphOutputs, 0},
+	} {
+		if err := g.Connect(c.from, c.out, c.to, c.in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := makeBufs(1, 8)
+	g.Tick(nil, out)
+	want := fix.S17(64).SMul(fix.S17(64).SMul(fix.MaxS17))
+	for i, got := range out[0] {
+		if got != want {
+			t.Errorf("out[0][%d] = %v, want: %v", i, got, want)
+		}
+	}
+}
+
+func TestGraphErrors(t *testing.T) {
+	g := NewGraph(1, 1)
+	a := g.Add(Noop{N: 1})
+	b := g.Add(Noop{N: 1})
+	for _, c := range []struct {
+		name string
+		from Node
+		out  int
+		to   Node
+		in   int
+	}{
+		{"bad output", a, 1, b, 0},
+		{"bad input", a, 0, b, -1},
+		{"missing node", a, 0, 7, 0},
+		{"from graph outputs", GraphOutputs, 0, b, 0},
+		{"to graph inputs", a, 0, GraphInputs, 0},
+	} {
+		if err := g.Connect(c.from, c.out, c.to, c.in); err == nil {
+			t.Errorf("%s: Connect(%d, %d, %d, %d) succeeded", c.name, c.from, c.out, c.to, c.in)
+		}
+	}
+}
+
+func TestGraphFeedback(t *testing.T) {
+	for _, c := range []struct {
+		delay, block int
+	}{
+		{4, 16},
+		{4, 3},
+		{4, 4},
+		{1, 16},
+		{7, 5},
+	} {
+		// An impulse into a node that feeds itself back at half gain.
+		g := NewGraph(1, 1)
+		n := g.Add(Noop{N: 1})
+		s := g.Add(Scale{Mul: 64})
+		for _, e := range [][2]Node{
+			{GraphInputs, n},
+			{n, s},
+			{s, n},
+			{n, GraphOutputs},
+		} {
+			if err := g.Connect(e[0], 0, e[1], 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		g.SetFeedbackDelay(c.delay)
+		if got := g.FeedbackLatency(); got != c.delay {
+			t.Errorf("delay %d: FeedbackLatency() = %d", c.delay, got)
+		}
+
+		const total = 32
+		in := make([]fix.S17, total)
+		in[0] = 64
+		got := make([]fix.S17, total)
+		for start := 0; start < total; start += c.block {
+			end := min(total, start+c.block)
+			g.Tick([][]fix.S17{in[start:end]}, [][]fix.S17{got[start:end]})
+		}
+		want := make([]fix.S17, total)
+		for i, v := 0, fix.S17(64); i < total && v != 0; i, v = i+c.delay, v/2 {
+			want[i] = v
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("delay %d, block %d: out[%d] = %v, want: %v", c.delay, c.block, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestGraphPrepareKeepsFeedback(t *testing.T) {
+	// Preparing between ticks shouldn't lose what's going round the loop.
+	g := NewGraph(1, 1)
+	n := g.Add(Noop{N: 1})
+	s := g.Add(Scale{Mul: 64})
+	for _, e := range [][2]Node{
+		{GraphInputs, n},
+		{n, s},
+		{s, n},
+		{n, GraphOutputs},
+	} {
+		if err := g.Wire(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.SetFeedbackDelay(4)
+	g.Prepare(4)
+
+	in := make([]fix.S17, 16)
+	in[0] = 64
+	got := make([]fix.S17, 16)
+	g.Tick([][]fix.S17{in[:4]}, [][]fix.S17{got[:4]})
+	empty := [][]fix.S17{{}}
+	for _, block := range []int{2, 8} {
+		g.Prepare(block)
+		if allocs := testing.AllocsPerRun(1, func() { g.Tick(empty, empty) }); allocs != 0 {
+			t.Errorf("Tick after Prepare(%d) allocated %v times", block, allocs)
+		}
+	}
+	g.Tick([][]fix.S17{in[4:]}, [][]fix.S17{got[4:]})
+	want := []fix.S17{64, 0, 0, 0, 32, 0, 0, 0, 16, 0, 0, 0, 8, 0, 0, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("out = %v, want: %v", got, want)
+	}
+}
+
+func TestGraphFeedbackAddOrder(t *testing.T) {
+	// Only the edges in the loop should be delayed, no matter whether the
+	// node after it was added first.
+	for _, mFirst := range []bool{true, false} {
+		g := NewGraph(1, 1)
+		var m Node
+		if mFirst {
+			m = g.Add(Scale{Mul: 64})
+		}
+		n := g.Add(Noop{N: 1})
+		s := g.Add(Scale{Mul: 64})
+		if !mFirst {
+			m = g.Add(Scale{Mul: 64})
+		}
+		for _, e := range [][2]Node{
+			{GraphInputs, n},
+			{n, s},
+			{s, n},
+			{n, m},
+			{m, GraphOutputs},
+		} {
+			if err := g.Wire(e[0], e[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		g.SetFeedbackDelay(4)
+
+		in := make([]fix.S17, 8)
+		in[0] = 64
+		got := make([]fix.S17, 8)
+		g.Tick([][]fix.S17{in}, [][]fix.S17{got})
+		if got[0] != 32 {
+			t.Errorf("m first %v: out[0] = %v, want: %v", mFirst, got[0], fix.S17(32))
+		}
+	}
+}
+
+func TestGraphNoFeedbackLatency(t *testing.T) {
+	g := NewGraph(1, 1)
+	n := g.Add(Noop{N: 1})
+	for _, e := range [][2]Node{{GraphInputs, n}, {n, GraphOutputs}} {
+		if err := g.Wire(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := g.FeedbackLatency(); got != 0 {
+		t.Errorf("FeedbackLatency() = %d, want: 0", got)
+	}
+}
+
+func TestGraphMeter(t *testing.T) {
+	g := NewGraph(1, 1)
+	a := g.Add(Const{Val: 10})
+	n := g.Add(Noop{N: 1})
+	for _, from := range []Node{GraphInputs, a} {
+		if err := g.Connect(from, 0, n, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Saturation() != nil {
+		t.Error("unmetered graph returned Saturation")
+	}
+	g.Meter()
+	// Added after metering is turned on, and saturates inside.
+	m := g.Add(Mixer{Gains: []fix.S17{fix.MaxS17, fix.MaxS17}})
+	if err := errors.Join(g.Connect(n, 0, m, 0), g.Connect(n, 0, m, 1), g.Wire(m, GraphOutputs)); err != nil {
+		t.Fatal(err)
+	}
+	in := [][]fix.S17{{0, 5, 120, -20, 125, 10}}
+	g.Tick(in, makeBufs(1, 6))
+	g.Tick(in, makeBufs(1, 6))
+
+	var ops, graphOps uint64
+	if fix.Counting {
+		// The two 127 + 127s each block, and the mixing into n.
+		ops, graphOps = 4, 8
+	}
+	want := []Saturation{
+		{Node: a, Name: "Const(0.0781250)", FullScale: []uint64{0}, Mixed: []EdgeSaturation{}},
+		{Node: n, Name: "Noop(1)", FullScale: []uint64{4}, Mixed: []EdgeSaturation{
+			{From: GraphInputs, Output: 0, Input: 0, Count: 0},
+			{From: a, Output: 0, Input: 0, Count: 4},
+		}},
+		{Node: m, Name: g.nodes[m].String(), Ops: ops, FullScale: []uint64{4}, Mixed: []EdgeSaturation{
+			{From: n, Output: 0, Input: 0},
+			{From: n, Output: 0, Input: 1},
+		}},
+		{Node: GraphOutputs, Name: "graph outputs", FullScale: []uint64{}, Mixed: []EdgeSaturation{
+			{From: m, Output: 0, Input: 0},
+		}},
+	}
+	got := g.Saturation()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Saturation() = %v, want: %v", got, want)
+	}
+	if got[1].Total() != 4 {
+		t.Errorf("Total() = %d, want: 4", got[1].Total())
+	}
+	if got := g.SaturatedOps(); got != graphOps {
+		t.Errorf("SaturatedOps() = %d, want: %d", got, graphOps)
+	}
+}
@@ -1,6 +1,8 @@
 package interp
 
 import (
+	"fmt"
+	"math"
 	"testing"
 
 	"github.com/pfcm/fxp/fix"
@@ -31,3 +33,149 @@ func TestL(t *testing.T) {
 	}
 
 }
+
+func TestInterpolators(t *testing.T) {
+	sinc8, err := NewSinc(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		in Interpolator
+		// exact is true if it should get straight lines exactly.
+		exact bool
+		// tol is the allowed error on a slow sine, in LSBs.
+		tol float64
+	}{
+		{Linear{}, true, 2},
+		{Hermite{}, true, 1},
+		{Lagrange{}, true, 1},
+		{sinc8, false, 1},
+	} {
+		x := make([]fix.S17, c.in.Taps())
+		for frac := 0; frac < 1<<16; frac += 1000 {
+			// A straight line through 0 at the point before.
+			for i := range x {
+				x[i] = fix.S17(20 * (i - c.in.Offset()))
+			}
+			want := 20 * float64(frac) / (1 << 16)
+			got := float64(c.in.At(x, fix.U016(frac)))
+			tol := 0.5
+			if !c.exact {
+				tol = 2
+			}
+			if d := math.Abs(got - want); d > tol {
+				t.Errorf("%v: line at %d = %v, want: %v", c.in, frac, got, want)
+			}
+
+			// A sine wave with 16 samples per cycle.
+			phase := func(i float64) float64 { return 2 * math.Pi * (i - float64(c.in.Offset())) / 16 }
+			for i := range x {
+				x[i] = fix.FromFloat(0.9 * math.Sin(phase(float64(i))))
+			}
+			want = 0.9 * 128 * math.Sin(phase(float64(c.in.Offset())+float64(frac)/(1<<16)))
+			got = float64(c.in.At(x, fix.U016(frac)))
+			if d := math.Abs(got - want); d > c.tol+0.5 {
+				t.Errorf("%v: sine at %d = %v, want: %v", c.in, frac, got, want)
+			}
+		}
+	}
+}
+
+func TestLagrangeCubic(t *testing.T) {
+	// The cubic through (-1, 4), (0, 0), (1, 10), (2, 16) is
+	// -2t^3 + 7t^2 + 5t.
+	x := []fix.S17{4, 0, 10, 16}
+	for _, frac := range []float64{0, 0.25, 0.5, 0.9} {
+		want := -2*frac*frac*frac + 7*frac*frac + 5*frac
+		got := Lagrange{}.At(x, fix.U016(frac*(1<<16)))
+		if math.Abs(float64(got)-want) > 0.5 {
+			t.Errorf("At(%v) = %v, want: %v", frac, got, want)
+		}
+	}
+}
+
+func TestAllpass(t *testing.T) {
+	// It settles on a constant, and delays a slow sine by the fraction.
+	const frac = 0.3
+	f := fix.U016FromFloat(frac)
+	a := &Allpass{}
+	var got []fix.S17
+	for i := range 200 {
+		x := []fix.S17{
+			fix.FromFloat(0.5 * math.Sin(2*math.Pi*float64(i)/64)),
+			fix.FromFloat(0.5 * math.Sin(2*math.Pi*float64(i+1)/64)),
+		}
+		got = append(got, a.At(x, f))
+	}
+	for i := 100; i < len(got); i++ {
+		want := 64 * math.Sin(2*math.Pi*(float64(i)+frac)/64)
+		if d := math.Abs(float64(got[i]) - want); d > 1.5 {
+			t.Errorf("out[%d] = %v, want: %v", i, got[i], want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"linear", "hermite", "lagrange", "allpass", "sinc8", "sinc32"} {
+		in, err := Parse(name)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", name, err)
+			continue
+		}
+		if got := fmt.Sprint(in); got != name {
+			t.Errorf("Parse(%q) = %v", name, got)
+		}
+	}
+	for _, name := range []string{"", "cubic", "sinc", "sinc7", "sinc1000"} {
+		if _, err := Parse(name); err == nil {
+			t.Errorf("Parse(%q) succeeded", name)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	in := make([]fix.S17, 1000)
+	for i := range in {
+		in[i] = fix.FromFloat(0.9 * math.Sin(2*math.Pi*float64(i)/50))
+	}
+	for _, c := range []struct {
+		from, to int
+	}{
+		{44100, 48000},
+		{48000, 44100},
+		{1, 2},
+		{3, 1},
+	} {
+		r, err := NewResampler(c.from, c.to, Hermite{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Awkward sized pieces at both ends.
+		var got []fix.S17
+		src := in
+		for len(src) > 0 {
+			dst := make([]fix.S17, 7)
+			read, written := r.Resample(dst, src[:min(len(src), 13)])
+			src = src[read:]
+			got = append(got, dst[:written]...)
+		}
+		ratio := float64(c.to) / float64(c.from)
+		if want := float64(len(in)) * ratio; math.Abs(float64(len(got))-want) > 2 {
+			t.Errorf("%d to %d: wrote %d samples, want: %v", c.from, c.to, len(got), want)
+		}
+		for i := 10; i < len(got)-10; i++ {
+			at := float64(i)/ratio - float64(r.Latency())
+			want := 0.9 * 128 * math.Sin(2*math.Pi*at/50)
+			if d := math.Abs(float64(got[i]) - want); d > 2 {
+				t.Errorf("%d to %d: out[%d] = %v, want: %v", c.from, c.to, i, got[i], want)
+				break
+			}
+		}
+	}
+	if _, err := NewResampler(44100, 48000, &Allpass{}); err == nil {
+		t.Error("NewResampler with Allpass succeeded")
+	}
+	if _, err := NewResampler(0, 44100, Linear{}); err == nil {
+		t.Error("NewResampler from 0Hz succeeded")
+	}
+}
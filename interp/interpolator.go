@@ -0,0 +1,200 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// MaxTaps is the most samples any Interpolator needs.
+const MaxTaps = 64
+
+// Interpolator estimates a signal between its samples.
+type Interpolator interface {
+	// Taps is the number of neighbouring samples it needs, no more than
+	// MaxTaps.
+	Taps() int
+	// Offset is the index in those samples of the one just before the
+	// point to interpolate.
+	Offset() int
+	// At interpolates x, which holds Taps() samples, at frac of the way
+	// from x[Offset()] to the sample after it.
+	At(x []fix.S17, frac fix.U016) fix.S17
+}
+
+var (
+	_ Interpolator = Linear{}
+	_ Interpolator = Hermite{}
+	_ Interpolator = Lagrange{}
+	_ Interpolator = &Allpass{}
+	_ Interpolator = &Sinc{}
+)
+
+// Parse returns a new Interpolator by name: linear, hermite, lagrange,
+// allpass, or sinc followed by the number of taps like sinc8. Allpass only
+// suits things that read every sample in turn, like a delay line.
+func Parse(name string) (Interpolator, error) {
+	switch name {
+	case "linear":
+		return Linear{}, nil
+	case "hermite":
+		return Hermite{}, nil
+	case "lagrange":
+		return Lagrange{}, nil
+	case "allpass":
+		return &Allpass{}, nil
+	}
+	var taps int
+	if _, err := fmt.Sscanf(name, "sinc%d", &taps); err == nil {
+		return NewSinc(taps)
+	}
+	return nil, fmt.Errorf("unknown interpolator %q", name)
+}
+
+// narrow rounds v, which has frac fractional bits, to the nearest S17,
+// saturating if it's out of range.
+func narrow(v int64, frac int) fix.S17 {
+	v >>= frac - 14
+	return fix.Acc32(min(max(v, math.MinInt32), math.MaxInt32)).S17R(fix.Nearest)
+}
+
+// Linear draws a straight line between the two nearest samples.
+type Linear struct{}
+
+func (Linear) Taps() int      { return 2 }
+func (Linear) Offset() int    { return 0 }
+func (Linear) String() string { return "linear" }
+
+func (Linear) At(x []fix.S17, frac fix.U016) fix.S17 {
+	a, b := int64(x[0]), int64(x[1])
+	return narrow(a<<16+(b-a)*int64(frac), 23)
+}
+
+// Hermite fits a cubic (Catmull-Rom) spline through four samples, so the
+// curve and its slope are continuous.
+type Hermite struct{}
+
+func (Hermite) Taps() int      { return 4 }
+func (Hermite) Offset() int    { return 1 }
+func (Hermite) String() string { return "hermite" }
+
+func (Hermite) At(x []fix.S17, frac fix.U016) fix.S17 {
+	x0, x1, x2, x3 := int64(x[0])<<16, int64(x[1])<<16, int64(x[2])<<16, int64(x[3])<<16
+	t := int64(frac)
+	// All the coefficients are doubled to keep them whole.
+	c1 := x2 - x0
+	c2 := 2*x0 - 5*x1 + 4*x2 - x3
+	c3 := x3 - x0 + 3*(x1-x2)
+	v := (c3*t)>>16 + c2
+	v = (v*t)>>16 + c1
+	v = (v*t)>>16 + 2*x1
+	return narrow(v, 24)
+}
+
+// Lagrange fits the cubic polynomial through four samples exactly.
+type Lagrange struct{}
+
+func (Lagrange) Taps() int      { return 4 }
+func (Lagrange) Offset() int    { return 1 }
+func (Lagrange) String() string { return "lagrange" }
+
+func (Lagrange) At(x []fix.S17, frac fix.U016) fix.S17 {
+	// The samples are at -1, 0, 1 and 2, with t between 0 and 1.
+	const one = 1 << 16
+	t := int64(frac)
+	tp1, tm1, tm2 := t+one, t-one, t-2*one
+	w := [4]int64{
+		-t * tm1 / one * tm2 / (6 * one),
+		tp1 * tm1 / one * tm2 / (2 * one),
+		-tp1 * t / one * tm2 / (2 * one),
+		tp1 * t / one * tm1 / (6 * one),
+	}
+	var v int64
+	for i, s := range x[:4] {
+		v += w[i] * int64(s)
+	}
+	return narrow(v, 23)
+}
+
+// Allpass is a first order allpass filter tuned to delay by the fraction.
+// Unlike the others it keeps no high frequency roll off, but it has state:
+// it only works on consecutive samples, and sounds best when the fraction
+// changes slowly.
+type Allpass struct {
+	// prev is the last output, with 23 fractional bits.
+	prev int64
+}
+
+func (*Allpass) Taps() int      { return 2 }
+func (*Allpass) Offset() int    { return 0 }
+func (*Allpass) String() string { return "allpass" }
+
+func (a *Allpass) At(x []fix.S17, frac fix.U016) fix.S17 {
+	// Delaying x[1] by d = 1 - frac needs a coefficient of
+	// (1 - d) / (1 + d) = frac / (2 - frac).
+	t := int64(frac)
+	eta := t << 16 / (2<<16 - t)
+	y := (eta*(int64(x[1])<<16-a.prev))>>16 + int64(x[0])<<16
+	a.prev = y
+	return narrow(y, 23)
+}
+
+// Sinc is a windowed sinc interpolator, the closest to ideal band limited
+// interpolation the more taps it has.
+type Sinc struct {
+	taps int
+	// coeffs holds taps coefficients with 15 fractional bits for each of
+	// 1<<sincPhaseBits+1 fractions, from 0 to 1 inclusive.
+	coeffs []int32
+}
+
+// sincPhaseBits is the number of bits of the fraction that pick the
+// coefficients. It's plenty for 8 bit output.
+const sincPhaseBits = 9
+
+// NewSinc returns a Sinc with the given number of taps, which needs to be
+// even and between 2 and MaxTaps.
+func NewSinc(taps int) (*Sinc, error) {
+	if taps < 2 || taps > MaxTaps || taps%2 != 0 {
+		return nil, fmt.Errorf("bad number of sinc taps %d", taps)
+	}
+	phases := 1 << sincPhaseBits
+	s := &Sinc{taps: taps, coeffs: make([]int32, (phases+1)*taps)}
+	half := float64(taps / 2)
+	w := make([]float64, taps)
+	for p := range phases + 1 {
+		frac := float64(p) / float64(phases)
+		sum := 0.0
+		for i := range w {
+			// Distance from the point, in samples.
+			u := float64(i-(taps/2-1)) - frac
+			// A Blackman window over the taps.
+			b := 0.42 + 0.5*math.Cos(math.Pi*u/half) + 0.08*math.Cos(2*math.Pi*u/half)
+			w[i] = b
+			if u != 0 {
+				w[i] *= math.Sin(math.Pi*u) / (math.Pi * u)
+			}
+			sum += w[i]
+		}
+		// Normalise so DC passes at unity gain.
+		for i, v := range w {
+			s.coeffs[p*taps+i] = int32(math.Round(v / sum * (1 << 15)))
+		}
+	}
+	return s, nil
+}
+
+func (s *Sinc) Taps() int      { return s.taps }
+func (s *Sinc) Offset() int    { return s.taps/2 - 1 }
+func (s *Sinc) String() string { return fmt.Sprintf("sinc%d", s.taps) }
+
+func (s *Sinc) At(x []fix.S17, frac fix.U016) fix.S17 {
+	p := (int(frac) + 1<<(15-sincPhaseBits)) >> (16 - sincPhaseBits)
+	c := s.coeffs[p*s.taps : (p+1)*s.taps]
+	var v int64
+	for i, s := range x[:len(c)] {
+		v += int64(c[i]) * int64(s)
+	}
+	return narrow(v, 22)
+}
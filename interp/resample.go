@@ -0,0 +1,64 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// Resampler converts a stream of samples from one sample rate to another.
+type Resampler struct {
+	in Interpolator
+	// step is the number of input samples per output sample, and pos is
+	// how far the next output is past window[in.Offset()], both with 16
+	// fractional bits.
+	step, pos int64
+	// window holds the last Taps() input samples.
+	window []fix.S17
+}
+
+// NewResampler returns a Resampler from one rate to another, which both need
+// to be positive and no more than 65536 times different. The interpolator
+// can't be an Allpass, which needs consecutive samples.
+func NewResampler(from, to int, in Interpolator) (*Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("can't resample from %dHz to %dHz", from, to)
+	}
+	if _, ok := in.(*Allpass); ok {
+		return nil, fmt.Errorf("can't resample with %v interpolation", in)
+	}
+	step := int64(from) << 16 / int64(to)
+	if step == 0 || step > 1<<32 {
+		return nil, fmt.Errorf("can't resample from %dHz to %dHz, the ratio is too big", from, to)
+	}
+	// Start off needing the first sample.
+	return &Resampler{in: in, step: step, pos: 1 << 16, window: make([]fix.S17, in.Taps())}, nil
+}
+
+// Latency is the delay added by the interpolator looking ahead, in input
+// samples.
+func (r *Resampler) Latency() int {
+	return r.in.Taps() - r.in.Offset() - 1
+}
+
+// Resample fills dst from src until it runs out of one or the other, and
+// returns how many samples it read and wrote. Call it again with the rest of
+// src and more space to carry on.
+func (r *Resampler) Resample(dst, src []fix.S17) (read, written int) {
+	for written < len(dst) {
+		if r.pos >= 1<<16 {
+			if read == len(src) {
+				break
+			}
+			copy(r.window, r.window[1:])
+			r.window[len(r.window)-1] = src[read]
+			read++
+			r.pos -= 1 << 16
+			continue
+		}
+		dst[written] = r.in.At(r.window, fix.U016(r.pos))
+		written++
+		r.pos += r.step
+	}
+	return read, written
+}
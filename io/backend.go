@@ -0,0 +1,185 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// StreamConfig describes an audio stream for a Backend to open.
+type StreamConfig struct {
+	SampleRate int
+	// PeriodSize is the preferred number of frames per callback, or 0 to
+	// let the backend decide.
+	PeriodSize int
+	// CaptureChannels and PlaybackChannels are the number of channels in
+	// each direction, 0 means the stream doesn't capture or play.
+	CaptureChannels, PlaybackChannels int
+	// CaptureDevice and PlaybackDevice are backend specific device
+	// names or IDs, empty for the default.
+	CaptureDevice, PlaybackDevice string
+	// Format is the sample format used in both directions.
+	Format SampleFormat
+}
+
+// Callback processes a period of audio. in holds the captured frames and out
+// needs to be filled with frames to play, both interleaved in the stream's
+// format. It is called on the backend's audio thread.
+type Callback func(out, in []byte, frames int)
+
+// Backend opens audio streams.
+type Backend interface {
+	// Open prepares a stream that calls cb once started.
+	Open(cfg StreamConfig, cb Callback) (Stream, error)
+}
+
+// Stream is an audio stream opened by a Backend.
+type Stream interface {
+	Start() error
+	// Close stops the stream and frees its resources. The callback is not
+	// called after Close returns.
+	Close() error
+}
+
+// Loopback is a Backend that doesn't need any hardware, for tests. It feeds
+// scripted input to the callback and captures everything it outputs, running
+// on a virtual clock as fast as it can until it has processed Frames frames.
+type Loopback struct {
+	// Input is the audio to capture, one slice per channel. Once it runs
+	// out, or if there are fewer channels than the stream wants, the
+	// input is silent.
+	Input [][]fix.S17
+	// Frames is the number of frames to process before stopping.
+	Frames int
+	// Periods lists the number of frames to pass to each callback, which
+	// is cycled through. If it's empty, the stream's PeriodSize is used,
+	// or 512 if that's 0 too.
+	Periods []int
+	// Formats, if it's not empty, lists the only sample formats that
+	// Open accepts.
+	Formats []SampleFormat
+
+	once   sync.Once
+	done   chan struct{}
+	mu     sync.Mutex
+	output [][]fix.S17
+}
+
+var _ Backend = &Loopback{}
+
+func (l *Loopback) init() {
+	l.once.Do(func() { l.done = make(chan struct{}) })
+}
+
+// Done returns a channel that is closed once the stream has processed all of
+// its frames.
+func (l *Loopback) Done() <-chan struct{} {
+	l.init()
+	return l.done
+}
+
+// Output returns a copy of everything the stream has played.
+func (l *Loopback) Output() [][]fix.S17 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([][]fix.S17, len(l.output))
+	for i, c := range l.output {
+		out[i] = append([]fix.S17(nil), c...)
+	}
+	return out
+}
+
+func (l *Loopback) Open(cfg StreamConfig, cb Callback) (Stream, error) {
+	if cfg.CaptureChannels == 0 && cfg.PlaybackChannels == 0 {
+		return nil, errors.New("stream with no channels")
+	}
+	if len(l.Formats) > 0 && !slices.Contains(l.Formats, cfg.Format) {
+		return nil, fmt.Errorf("unsupported sample format %v", cfg.Format)
+	}
+	for _, p := range l.Periods {
+		if p <= 0 {
+			return nil, fmt.Errorf("bad period size %d", p)
+		}
+	}
+	l.init()
+	l.mu.Lock()
+	l.output = make([][]fix.S17, cfg.PlaybackChannels)
+	l.mu.Unlock()
+	return &loopbackStream{l: l, cfg: cfg, cb: cb, stop: make(chan struct{})}, nil
+}
+
+type loopbackStream struct {
+	l    *Loopback
+	cfg  StreamConfig
+	cb   Callback
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (s *loopbackStream) Start() error {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run()
+	}()
+	return nil
+}
+
+func (s *loopbackStream) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *loopbackStream) period(i int) int {
+	switch {
+	case len(s.l.Periods) != 0:
+		return s.l.Periods[i%len(s.l.Periods)]
+	case s.cfg.PeriodSize > 0:
+		return s.cfg.PeriodSize
+	}
+	return 512
+}
+
+func (s *loopbackStream) run() {
+	defer close(s.l.done)
+	size := s.cfg.Format.Size()
+	var in, out []byte
+	for i, pos := 0, 0; pos < s.l.Frames; i++ {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		n := min(s.period(i), s.l.Frames-pos)
+		in = in[:0]
+		for f := pos; f < pos+n; f++ {
+			for c := 0; c < s.cfg.CaptureChannels; c++ {
+				var v fix.S17
+				if c < len(s.l.Input) && f < len(s.l.Input[c]) {
+					v = s.l.Input[c][f]
+				}
+				in = s.cfg.Format.encode(in, v)
+			}
+		}
+		if need := n * s.cfg.PlaybackChannels * size; cap(out) < need {
+			out = make([]byte, need)
+		} else {
+			out = out[:need]
+		}
+		s.cb(out, in, n)
+
+		s.l.mu.Lock()
+		for f := 0; f < n; f++ {
+			for c := range s.l.output {
+				j := (f*s.cfg.PlaybackChannels + c) * size
+				s.l.output[c] = append(s.l.output[c], s.cfg.Format.decode(out[j:j+size]))
+			}
+		}
+		s.l.mu.Unlock()
+		pos += n
+	}
+}
@@ -0,0 +1,126 @@
+package io
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// deinterleave decodes n frames of interleaved samples in src into one slice
+// per channel of dst. U8 and S16 are converted directly, without going via
+// float, unless there are quantizers, which need one per channel.
+func (f SampleFormat) deinterleave(dst [][]fix.S17, src []byte, n int, qs []fix.Quantizer) {
+	ch := len(dst)
+	switch {
+	case f != U8 && qs != nil:
+		size := f.Size()
+		src = src[:size*n*ch]
+		for c, d := range dst {
+			d = d[:n]
+			for i := range d {
+				j := (i*ch + c) * size
+				d[i] = qs[c].Quantize(f.float(src[j : j+size]))
+			}
+		}
+	case f == U8:
+		src = src[:n*ch]
+		for c, d := range dst {
+			d = d[:n]
+			for i := range d {
+				d[i] = fix.S17(int8(src[i*ch+c] ^ 0x80))
+			}
+		}
+	case f == S16:
+		// The top byte of a little endian S16 is exactly an S17, the
+		// bottom byte is below its resolution.
+		src = src[:2*n*ch]
+		for c, d := range dst {
+			d = d[:n]
+			for i := range d {
+				d[i] = fix.S17(int8(src[2*(i*ch+c)+1]))
+			}
+		}
+	default:
+		size := f.Size()
+		src = src[:size*n*ch]
+		for c, d := range dst {
+			d = d[:n]
+			for i := range d {
+				j := (i*ch + c) * size
+				d[i] = f.decode(src[j : j+size])
+			}
+		}
+	}
+}
+
+// float converts a single sample to a float.
+func (f SampleFormat) float(b []byte) float32 {
+	switch f {
+	case U8:
+		return float32(int8(b[0]^0x80)) / (1 << 7)
+	case S16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+// roundRobin uses a Quantizer for each channel of interleaved samples in
+// turn, so that it can quantize a whole frame as one channel.
+type roundRobin struct {
+	qs []fix.Quantizer
+	i  int
+}
+
+func (r *roundRobin) Quantize(f float32) fix.S17 {
+	s := r.qs[r.i].Quantize(f)
+	r.i = (r.i + 1) % len(r.qs)
+	return s
+}
+
+// quantizers returns a Quantizer of kind q for each of n channels, or nil if
+// it's fix.Truncate, which deinterleave does without.
+func quantizers(q fix.Quantization, n int) []fix.Quantizer {
+	if q == fix.Truncate {
+		return nil
+	}
+	qs := make([]fix.Quantizer, n)
+	for i := range qs {
+		qs[i] = q.New()
+	}
+	return qs
+}
+
+// interleave is the opposite of deinterleave, encoding n frames from src
+// into dst.
+func (f SampleFormat) interleave(dst []byte, src [][]fix.S17, n int) {
+	ch := len(src)
+	switch f {
+	case U8:
+		dst = dst[:n*ch]
+		for c, s := range src {
+			for i, v := range s[:n] {
+				dst[i*ch+c] = byte(v) ^ 0x80
+			}
+		}
+	case S16:
+		dst = dst[:2*n*ch]
+		for c, s := range src {
+			for i, v := range s[:n] {
+				j := 2 * (i*ch + c)
+				dst[j] = 0
+				dst[j+1] = byte(v)
+			}
+		}
+	default:
+		size := f.Size()
+		dst = dst[:size*n*ch]
+		for c, s := range src {
+			for i, v := range s[:n] {
+				// encode appends, so this writes in place.
+				j := (i*ch + c) * size
+				f.encode(dst[j:j], v)
+			}
+		}
+	}
+}
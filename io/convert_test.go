@@ -0,0 +1,72 @@
+package io
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+func TestInterleave(t *testing.T) {
+	all := make([]fix.S17, 256)
+	for i := range all {
+		all[i] = fix.S17(i - 128)
+	}
+	rev := slices.Clone(all)
+	slices.Reverse(rev)
+	for _, f := range []SampleFormat{U8, S16, F32} {
+		src := [][]fix.S17{all, rev}
+		b := make([]byte, 2*256*f.Size())
+		f.interleave(b, src, 256)
+		// Should match encoding one at a time.
+		var want []byte
+		for i := range all {
+			want = f.encode(want, all[i])
+			want = f.encode(want, rev[i])
+		}
+		if !slices.Equal(b, want) {
+			t.Errorf("%v: interleave = %v, want: %v", f, b, want)
+		}
+
+		got := [][]fix.S17{make([]fix.S17, 256), make([]fix.S17, 256)}
+		f.deinterleave(got, b, 256, nil)
+		for c := range got {
+			if !slices.Equal(got[c], src[c]) {
+				t.Errorf("%v: deinterleave channel %d = %v, want: %v", f, c, got[c], src[c])
+			}
+		}
+	}
+}
+
+func BenchmarkInterleave(b *testing.B) {
+	const n = 512
+	bufs := [][]fix.S17{make([]fix.S17, n), make([]fix.S17, n)}
+	for _, f := range []SampleFormat{U8, S16, F32} {
+		raw := make([]byte, 2*n*f.Size())
+		b.Run(f.String(), func(b *testing.B) {
+			b.SetBytes(int64(len(raw)))
+			for range b.N {
+				f.deinterleave(bufs, raw, n, nil)
+				f.interleave(raw, bufs, n)
+			}
+		})
+	}
+}
+
+func TestDeinterleaveQuantized(t *testing.T) {
+	// Two channels of S16: 0x0A80 is 10.5 S17 LSBs and 0x0A7F a bit less.
+	src := []byte{0x80, 0x0A, 0x7F, 0x0A}
+	for _, c := range []struct {
+		qs   []fix.Quantizer
+		want []fix.S17
+	}{
+		{nil, []fix.S17{10, 10}},
+		{quantizers(fix.Round, 2), []fix.S17{11, 10}},
+	} {
+		got := [][]fix.S17{{0}, {0}}
+		S16.deinterleave(got, src, 1, c.qs)
+		if got[0][0] != c.want[0] || got[1][0] != c.want[1] {
+			t.Errorf("quantizers %v: got %v, want: %v", c.qs, got, c.want)
+		}
+	}
+}
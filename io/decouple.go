@@ -0,0 +1,161 @@
+package io
+
+import (
+	"context"
+	"time"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+// decoupled runs a Ticker on its own goroutine at a fixed block size,
+// swapping audio with the device callback through fifos so that the callback
+// never waits for the Ticker.
+type decoupled struct {
+	t      fxp.Ticker
+	block  int
+	format SampleFormat
+	rate   int
+	stats  *Stats
+	// in carries captured frames to the Ticker and out carries the
+	// Ticker's frames to the device, either is nil if there are no
+	// channels in that direction.
+	in, out *fifo
+	// wake is poked by the callback whenever it has changed the fifos.
+	wake chan struct{}
+
+	// Used by the processing goroutine.
+	inputs, outputs [][]fix.S17
+	frames          []fix.S17
+	// Used by the callback, scratch holds interleaved frames and
+	// scratchCh is just scratch as a single channel for deinterleave.
+	scratch   []fix.S17
+	scratchCh [][]fix.S17
+	// quantizers is nil or a roundRobin for scratchCh.
+	quantizers []fix.Quantizer
+}
+
+// newDecoupled returns a decoupled for t with the block size and headroom
+// from opts, exchanging samples in the given format.
+func newDecoupled(t fxp.Ticker, format SampleFormat, opts Options) *decoupled {
+	block, headroom := opts.BlockSize, opts.headroom()
+	fxp.Prepare(t, block)
+	d := &decoupled{
+		t:       t,
+		block:   block,
+		format:  format,
+		rate:    opts.sampleRate(),
+		stats:   opts.Stats,
+		wake:    make(chan struct{}, 1),
+		inputs:  make([][]fix.S17, t.Inputs()),
+		outputs: make([][]fix.S17, t.Outputs()),
+		frames:  make([]fix.S17, block*max(t.Inputs(), t.Outputs())),
+		scratch: make([]fix.S17, block*max(t.Inputs(), t.Outputs())),
+	}
+	d.scratchCh = [][]fix.S17{d.scratch}
+	if qs := quantizers(opts.Quantization, t.Inputs()); qs != nil {
+		d.quantizers = []fix.Quantizer{&roundRobin{qs: qs}}
+	}
+	for i := range d.inputs {
+		d.inputs[i] = make([]fix.S17, block)
+	}
+	for i := range d.outputs {
+		d.outputs[i] = make([]fix.S17, block)
+	}
+	if t.Inputs() > 0 {
+		d.in = newFIFO(headroom*block, t.Inputs())
+	}
+	if t.Outputs() > 0 {
+		d.out = newFIFO(headroom*block, t.Outputs())
+		if d.in != nil {
+			// Output can only be made as fast as input arrives,
+			// so start with enough silence to cover a whole period
+			// plus a partial block.
+			d.out.push(make([]fix.S17, (headroom-1)*block*t.Outputs()))
+		}
+	}
+	return d
+}
+
+// ready reports whether there is a block of input and room for a block of
+// output.
+func (d *decoupled) ready() bool {
+	return (d.in == nil || d.in.frames() >= d.block) &&
+		(d.out == nil || d.out.space() >= d.block)
+}
+
+// fill processes blocks until the Ticker can't go any further.
+func (d *decoupled) fill() {
+	for d.ready() {
+		d.process()
+	}
+}
+
+// run processes blocks as space and input become available, until the
+// context is cancelled.
+func (d *decoupled) run(ctx context.Context) {
+	for {
+		d.fill()
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		}
+	}
+}
+
+// process runs the Ticker over one block.
+func (d *decoupled) process() {
+	start := time.Now()
+	defer d.stats.processed(start, d.block, d.rate)
+	if d.in != nil {
+		d.in.pop(d.frames[:d.block*len(d.inputs)])
+		for i, s := range d.frames[:d.block*len(d.inputs)] {
+			d.inputs[i%len(d.inputs)][i/len(d.inputs)] = s
+		}
+	}
+	d.t.Tick(d.inputs, d.outputs)
+	if d.out != nil {
+		for i := range d.frames[:d.block*len(d.outputs)] {
+			d.frames[i] = d.outputs[i%len(d.outputs)][i/len(d.outputs)]
+		}
+		d.out.push(d.frames[:d.block*len(d.outputs)])
+	}
+}
+
+// callback is the Callback for the device. If the Ticker has fallen behind,
+// captured frames that don't fit are dropped and missing output frames are
+// played as silence.
+func (d *decoupled) callback(out, in []byte, frames int) {
+	d.stats.callback(frames)
+	size := d.format.Size()
+	if d.in != nil {
+		ch := d.in.channels
+		chunk := len(d.scratch) / ch
+		for start := 0; start < frames; start += chunk {
+			n := min(chunk, frames-start)
+			d.format.deinterleave(d.scratchCh, in[start*ch*size:], n*ch, d.quantizers)
+			if got := d.in.push(d.scratch[:n*ch]); got < n {
+				// Overrun, the rest is dropped.
+				d.stats.overrun(frames - start - got)
+				break
+			}
+		}
+	}
+	if d.out != nil {
+		ch := d.out.channels
+		chunk := len(d.scratch) / ch
+		for start := 0; start < frames; start += chunk {
+			n := min(chunk, frames-start)
+			got := d.out.pop(d.scratch[:n*ch])
+			// Underrun if got < n, fill with silence.
+			d.stats.underrun(n - got)
+			clear(d.scratch[got*ch : n*ch])
+			d.format.interleave(out[start*ch*size:], d.scratchCh, n*ch)
+		}
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package io
+
+import (
+	"sync/atomic"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// fifo is a lock-free queue of interleaved frames for exactly one producer
+// and one consumer, which can be on different goroutines.
+type fifo struct {
+	buf      []fix.S17
+	channels int
+	// read and write count the frames ever read and written. Only the
+	// consumer writes read and only the producer writes write.
+	read, write atomic.Uint64
+}
+
+// newFIFO returns a fifo with room for the given number of frames.
+func newFIFO(frames, channels int) *fifo {
+	return &fifo{
+		buf:      make([]fix.S17, frames*channels),
+		channels: channels,
+	}
+}
+
+func (f *fifo) capacity() int { return len(f.buf) / f.channels }
+
+// frames returns the number of frames waiting to be read.
+func (f *fifo) frames() int {
+	return int(f.write.Load() - f.read.Load())
+}
+
+// space returns the number of frames that can be written.
+func (f *fifo) space() int {
+	return f.capacity() - f.frames()
+}
+
+// push writes as many whole frames from src as there is room for and returns
+// how many that was.
+func (f *fifo) push(src []fix.S17) int {
+	w := f.write.Load()
+	n := min(len(src)/f.channels, f.capacity()-int(w-f.read.Load()))
+	start := int(w%uint64(f.capacity())) * f.channels
+	c := copy(f.buf[start:], src[:n*f.channels])
+	copy(f.buf, src[c:n*f.channels])
+	f.write.Store(w + uint64(n))
+	return n
+}
+
+// pop reads as many whole frames into dst as are available and fit, and
+// returns how many that was.
+func (f *fifo) pop(dst []fix.S17) int {
+	r := f.read.Load()
+	n := min(len(dst)/f.channels, int(f.write.Load()-r))
+	start := int(r%uint64(f.capacity())) * f.channels
+	c := copy(dst[:n*f.channels], f.buf[start:])
+	copy(dst[c:n*f.channels], f.buf)
+	f.read.Store(r + uint64(n))
+	return n
+}
@@ -0,0 +1,69 @@
+package io
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+func TestFIFO(t *testing.T) {
+	f := newFIFO(4, 2)
+	if n := f.push([]fix.S17{1, 2, 3, 4, 5, 6}); n != 3 {
+		t.Errorf("push 3 frames: pushed %d", n)
+	}
+	if n := f.push([]fix.S17{7, 8, 9, 10}); n != 1 {
+		t.Errorf("push 2 frames into space for 1: pushed %d", n)
+	}
+	dst := make([]fix.S17, 6)
+	if n := f.pop(dst); n != 3 || !slices.Equal(dst, []fix.S17{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("pop: got %d frames: %v", n, dst)
+	}
+	// This one wraps around.
+	if n := f.push([]fix.S17{11, 12, 13, 14}); n != 2 {
+		t.Errorf("push 2 frames: pushed %d", n)
+	}
+	if n := f.pop(dst); n != 3 || !slices.Equal(dst, []fix.S17{7, 8, 11, 12, 13, 14}) {
+		t.Errorf("pop after wrapping: got %d frames: %v", n, dst)
+	}
+	if n := f.pop(dst); n != 0 {
+		t.Errorf("pop from empty fifo: got %d frames", n)
+	}
+}
+
+func TestFIFOConcurrent(t *testing.T) {
+	const total = 10000
+	f := newFIFO(37, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]fix.S17, 11)
+		for i := 0; i < total; {
+			for j := range buf {
+				buf[j] = fix.S17(i + j)
+			}
+			n := f.push(buf[:min(len(buf), total-i)])
+			if n == 0 {
+				runtime.Gosched()
+			}
+			i += n
+		}
+	}()
+	buf := make([]fix.S17, 13)
+	for i := 0; i < total; {
+		n := f.pop(buf)
+		for j, s := range buf[:n] {
+			if want := fix.S17(i + j); s != want {
+				t.Fatalf("sample %d = %v, want: %v", i+j, s, want)
+			}
+		}
+		if n == 0 {
+			runtime.Gosched()
+		}
+		i += n
+	}
+	wg.Wait()
+}
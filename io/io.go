@@ -3,97 +3,245 @@ package io
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
-	"math"
-	"os"
-
-	"github.com/gen2brain/malgo"
+	"time"
 
 	"github.com/pfcm/fxp"
 	"github.com/pfcm/fxp/fix"
 )
 
+// DefaultSampleRate is the sample rate used if Options.SampleRate is 0.
+const DefaultSampleRate = 44100
+
+// Options configures the audio devices used by Play. The zero value uses the
+// default devices at DefaultSampleRate.
+type Options struct {
+	// SampleRate is the sample rate to run the devices at.
+	SampleRate int
+	// PeriodSize is the number of frames the device should ask for at a
+	// time. If it's 0 the backend decides, which is usually sensible.
+	PeriodSize int
+	// CaptureDevice and PlaybackDevice select the devices to use, by the
+	// ID or name reported by Devices. If they're empty the system default
+	// is used.
+	CaptureDevice, PlaybackDevice string
+	// Backend opens the devices, if it's nil the system's audio devices
+	// are used via Malgo.
+	Backend Backend
+
+	// BlockSize, if it's not 0, decouples the Ticker from the device:
+	// the Ticker runs on its own goroutine, always in blocks of exactly
+	// BlockSize frames, and swaps audio with the device callback through
+	// lock-free buffers. This adds latency, but a slow block doesn't
+	// stall the device and irregular callback sizes don't matter.
+	BlockSize int
+	// Headroom is the number of blocks buffered in each direction when
+	// decoupled. More headroom absorbs more jitter at the cost of
+	// latency. If it's 0 there is just enough to cover a period, which
+	// without a PeriodSize means fxp.DefaultMaxBlock frames, so set
+	// PeriodSize to keep the latency down.
+	Headroom int
+
+	// Formats are the sample formats to try opening the devices with, in
+	// order. The default prefers U8, which holds S17 samples exactly,
+	// then S16 and finally F32. If Quantization is set the default
+	// prefers F32, so that there is something to quantize.
+	Formats []SampleFormat
+	// Quantization is how captured audio is reduced to S17.
+	Quantization fix.Quantization
+
+	// Stats, if it's not nil, is updated with timing information and
+	// xrun counts while playing.
+	Stats *Stats
+}
+
+func (o Options) sampleRate() int {
+	if o.SampleRate <= 0 {
+		return DefaultSampleRate
+	}
+	return o.SampleRate
+}
+
+// maxBlock is the largest block the Ticker will be asked to process.
+func (o Options) maxBlock() int {
+	if o.PeriodSize > 0 {
+		return o.PeriodSize
+	}
+	return fxp.DefaultMaxBlock
+}
+
+// headroom is the number of blocks to buffer when decoupled.
+func (o Options) headroom() int {
+	if o.Headroom > 0 {
+		return o.Headroom
+	}
+	// Without a PeriodSize the backend can't tell us how big its
+	// callbacks will be, so allow for the biggest we'd handle anyway.
+	period := o.maxBlock()
+	// Enough for a whole period, a partial block left over from the last
+	// one and a block being processed.
+	return (period+o.BlockSize-1)/o.BlockSize + 2
+}
+
+func (o Options) formats() []SampleFormat {
+	switch {
+	case len(o.Formats) > 0:
+		return o.Formats
+	case o.Quantization != fix.Truncate:
+		return []SampleFormat{F32, S16, U8}
+	}
+	return []SampleFormat{U8, S16, F32}
+}
+
+func (o Options) backend() Backend {
+	if o.Backend == nil {
+		return Malgo{}
+	}
+	return o.Backend
+}
+
+// Device describes an audio device.
+type Device struct {
+	ID      string
+	Name    string
+	Default bool
+}
+
+func (d Device) String() string {
+	s := fmt.Sprintf("%s (%s)", d.Name, d.ID)
+	if d.Default {
+		s += " [default]"
+	}
+	return s
+}
+
+// Devices lists the system's capture and playback devices.
+func Devices() (capture, playback []Device, err error) {
+	return Malgo{}.Devices()
+}
+
 // PlayWithDefaults uses the default input and outputs to run the provided
 // Ticker. It blocks until the provided context is cancelled.
 func PlayWithDefaults(ctx context.Context, t fxp.Ticker) error {
-	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(msg string) {
-		fmt.Fprint(os.Stderr, msg)
-	})
-	if err != nil {
+	return Play(ctx, t, Options{})
+}
+
+// Play runs the provided Ticker on audio devices until the context is
+// cancelled. Tickers with no inputs only open a playback device and Tickers
+// with no outputs only open a capture device.
+func Play(ctx context.Context, t fxp.Ticker, opts Options) error {
+	if err := fxp.Validate(t); err != nil {
 		return err
 	}
-	defer func() {
-		mctx.Uninit()
-		mctx.Free()
-	}()
-	cfg := malgo.DefaultDeviceConfig(malgo.Duplex)
-	// TODO: ???
-	inps := max(1, t.Inputs())
-	cfg.Capture.Format = malgo.FormatF32
-	cfg.Capture.Channels = uint32(inps)
-	cfg.Playback.Format = malgo.FormatF32
-	cfg.Playback.Channels = uint32(t.Outputs())
-	cfg.SampleRate = 44100
-
-	// TODO: do we know the sizes ahead of the first recv call?
-	inputs := make([][]fix.S17, inps)
-	for i := range inputs {
-		inputs[i] = make([]fix.S17, 4096)
-	}
-	outputs := make([][]fix.S17, t.Outputs())
-	for i := range outputs {
-		outputs[i] = make([]fix.S17, 4096)
+	if t.Inputs() == 0 && t.Outputs() == 0 {
+		return errors.New("nothing to play: ticker has no inputs or outputs")
 	}
 
-	recv := func(out, in []byte, framecount uint32) {
-		if framecount == 0 {
-			return
-		}
-		// de-interleave and reformat the samples. Each input sample is
-		// 4 bytes.
-		frameSize := 4 * inps
-		for i := 0; i < len(in); i += frameSize {
-			for c := range inputs {
-				// Convert from float 32 to unsigned 8
-				j := i + c*4
-				u := binary.LittleEndian.Uint32(in[j:])
-				f := math.Float32frombits(u)
-				inputs[c][i/frameSize] = fix.FromFloat(f)
-			}
-		}
-		for i, inp := range inputs {
-			// Make sure the bounds are correct.
-			inputs[i] = inp[:framecount]
-		}
-		for i, outp := range outputs {
-			outputs[i] = outp[:framecount]
+	if opts.BlockSize < 0 || opts.Headroom < 0 {
+		return fmt.Errorf("bad block size %d or headroom %d", opts.BlockSize, opts.Headroom)
+	}
+	var d *decoupled
+	if opts.BlockSize > 0 {
+		d = newDecoupled(t, F32, opts)
+	}
+	var (
+		stream Stream
+		errs   []error
+	)
+	for _, f := range opts.formats() {
+		var cb Callback
+		if d != nil {
+			d.format = f
+			cb = d.callback
+		} else {
+			cb = direct(t, f, opts)
 		}
-		// Run the ticker.
-		t.Tick(inputs, outputs)
-
-		// reformat the output to float32 and re-interleave.
-		o := out[:0]
-		for i := 0; i < int(framecount); i++ {
-			for c := range outputs {
-				f := fix.Float[float32](outputs[c][i])
-				o = binary.LittleEndian.AppendUint32(o, math.Float32bits(f))
-			}
+		s, err := opts.backend().Open(StreamConfig{
+			SampleRate:       opts.sampleRate(),
+			PeriodSize:       opts.PeriodSize,
+			CaptureChannels:  t.Inputs(),
+			PlaybackChannels: t.Outputs(),
+			CaptureDevice:    opts.CaptureDevice,
+			PlaybackDevice:   opts.PlaybackDevice,
+			Format:           f,
+		}, cb)
+		if err == nil {
+			stream = s
+			break
 		}
+		errs = append(errs, fmt.Errorf("%v: %w", f, err))
 	}
-
-	device, err := malgo.InitDevice(mctx.Context, cfg, malgo.DeviceCallbacks{
-		Data: recv,
-	})
-	if err != nil {
-		return err
+	if stream == nil {
+		return errors.Join(errs...)
+	}
+	if d != nil {
+		// Get some output ready before the device asks for it.
+		d.fill()
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.run(runCtx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
 	}
-	if err := device.Start(); err != nil {
+	if err := stream.Start(); err != nil {
+		stream.Close()
 		return err
 	}
 
 	<-ctx.Done()
 
-	device.Uninit()
-	return nil
+	return stream.Close()
+}
+
+// direct returns a Callback that runs t on the audio thread, exchanging
+// samples in the given format.
+func direct(t fxp.Ticker, f SampleFormat, opts Options) Callback {
+	maxBlock, rate, stats := opts.maxBlock(), opts.sampleRate(), opts.Stats
+	qs := quantizers(opts.Quantization, t.Inputs())
+	// Devices can still call back with more than the period size, so
+	// split up anything larger.
+	fxp.Prepare(t, maxBlock)
+	inputs := make([][]fix.S17, t.Inputs())
+	for i := range inputs {
+		inputs[i] = make([]fix.S17, maxBlock)
+	}
+	outputs := make([][]fix.S17, t.Outputs())
+	for i := range outputs {
+		outputs[i] = make([]fix.S17, maxBlock)
+	}
+
+	return func(out, in []byte, framecount int) {
+		stats.callback(framecount)
+		start := time.Now()
+		defer stats.processed(start, framecount, rate)
+		inFrame, outFrame := f.Size()*len(inputs), f.Size()*len(outputs)
+		for start := 0; start < framecount; start += maxBlock {
+			n := min(framecount-start, maxBlock)
+			tick(t, f, qs, inputs, outputs,
+				out[start*outFrame:(start+n)*outFrame],
+				in[start*inFrame:(start+n)*inFrame],
+				n)
+		}
+	}
+}
+
+// tick processes a block of n frames of interleaved samples, which must fit
+// in inputs and outputs. qs quantize the input if they're not nil.
+func tick(t fxp.Ticker, f SampleFormat, qs []fix.Quantizer, inputs, outputs [][]fix.S17, out, in []byte, n int) {
+	for i, inp := range inputs {
+		// Make sure the bounds are correct.
+		inputs[i] = inp[:n]
+	}
+	for i, outp := range outputs {
+		outputs[i] = outp[:n]
+	}
+	f.deinterleave(inputs, in, n, qs)
+	t.Tick(inputs, outputs)
+	f.interleave(out, outputs, n)
 }
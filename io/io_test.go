@@ -0,0 +1,292 @@
+package io
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+// play runs t on lb until it's done.
+func play(t *testing.T, tk fxp.Ticker, opts Options) {
+	t.Helper()
+	lb := opts.Backend.(*Loopback)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-lb.Done()
+		cancel()
+	}()
+	if err := Play(ctx, tk, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ramp(n int) []fix.S17 {
+	out := make([]fix.S17, n)
+	for i := range out {
+		out[i] = fix.S17(i%256 - 128)
+	}
+	return out
+}
+
+func TestPlayLoopback(t *testing.T) {
+	in := ramp(1000)
+	for _, c := range []struct {
+		name       string
+		periodSize int
+		periods    []int
+		formats    []SampleFormat
+	}{
+		{"default", 0, nil, nil},
+		{"period size", 64, nil, nil},
+		{"irregular", 0, []int{1, 100, 37}, nil},
+		{"larger than period size", 16, []int{5, 40, 16}, nil},
+		{"S16", 0, []int{5, 40, 16}, []SampleFormat{S16, F32}},
+		{"F32", 0, []int{5, 40, 16}, []SampleFormat{F32}},
+	} {
+		lb := &Loopback{
+			Input:   [][]fix.S17{in},
+			Frames:  len(in),
+			Periods: c.periods,
+			Formats: c.formats,
+		}
+		tk := fxp.Serially(fxp.Mult{N: 2}, fxp.Concurrently(fxp.Noop{N: 1}, fxp.Scale{Mul: 64}))
+		play(t, tk, Options{Backend: lb, PeriodSize: c.periodSize})
+		got := lb.Output()
+		if len(got) != 2 {
+			t.Fatalf("%s: got %d channels, want 2", c.name, len(got))
+		}
+		want := make([]fix.S17, len(in))
+		for i, s := range in {
+			want[i] = s.SMul(64)
+		}
+		if !slices.Equal(got[0], in) {
+			t.Errorf("%s: channel 0 = %v, want: %v", c.name, got[0], in)
+		}
+		if !slices.Equal(got[1], want) {
+			t.Errorf("%s: channel 1 = %v, want: %v", c.name, got[1], want)
+		}
+	}
+}
+
+// sink counts the samples it receives.
+type sink struct{ n int }
+
+func (*sink) Inputs() int              { return 1 }
+func (*sink) Outputs() int             { return 0 }
+func (*sink) String() string           { return "sink" }
+func (s *sink) Tick(in, _ [][]fix.S17) { s.n += len(in[0]) }
+
+func TestPlayOneDirection(t *testing.T) {
+	lb := &Loopback{Frames: 300}
+	play(t, fxp.Const{Val: 3}, Options{Backend: lb})
+	got := lb.Output()
+	if len(got) != 1 || len(got[0]) != 300 || got[0][299] != 3 {
+		t.Errorf("playback only: got %v", got)
+	}
+
+	lb = &Loopback{Frames: 300, Input: [][]fix.S17{ramp(300)}}
+	s := &sink{}
+	play(t, s, Options{Backend: lb})
+	if s.n != 300 {
+		t.Errorf("capture only: sink got %d samples, want 300", s.n)
+	}
+	if got := lb.Output(); len(got) != 0 {
+		t.Errorf("capture only: got %d output channels", len(got))
+	}
+}
+
+func TestPlayErrors(t *testing.T) {
+	ctx := context.Background()
+	if err := Play(ctx, fxp.Mixer{}, Options{Backend: &Loopback{}}); err == nil {
+		t.Error("Play accepted an invalid ticker")
+	}
+	if err := Play(ctx, fxp.Noop{}, Options{Backend: &Loopback{}}); err == nil {
+		t.Error("Play accepted a ticker with no channels")
+	}
+	lb := &Loopback{Formats: []SampleFormat{F32}}
+	if err := Play(ctx, fxp.Const{}, Options{Backend: lb, Formats: []SampleFormat{U8, S16}}); err == nil {
+		t.Error("Play succeeded without a supported format")
+	}
+}
+
+// drive runs d, feeding in through its callback in periods of the given
+// sizes as a device would, and returns the two output channels.
+func drive(t *testing.T, name string, d *decoupled, in []fix.S17, periods []int) [2][]fix.S17 {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var got [2][]fix.S17
+	for i, pos := 0, 0; pos < len(in); i++ {
+		n := min(periods[i%len(periods)], len(in)-pos)
+		var b []byte
+		for _, s := range in[pos : pos+n] {
+			b = S16.encode(b, s)
+		}
+		// Give the Ticker a chance to keep up, so the result
+		// doesn't depend on scheduling.
+		deadline := time.Now().Add(5 * time.Second)
+		for d.out.frames() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: timed out waiting for %d frames at %d", name, n, pos)
+			}
+			runtime.Gosched()
+		}
+		out := make([]byte, 2*2*n)
+		d.callback(out, b, n)
+		for j := 0; j < 2*n; j++ {
+			got[j%2] = append(got[j%2], S16.decode(out[2*j:]))
+		}
+		pos += n
+	}
+	return got
+}
+
+// twoWay is a Ticker with an input and two outputs for testing decoupled.
+func twoWay() fxp.Ticker {
+	return fxp.Serially(fxp.Mult{N: 2}, fxp.Concurrently(fxp.Noop{N: 1}, fxp.Scale{Mul: 64}))
+}
+
+// checkDelayed checks that got is in, latency frames late, through twoWay.
+func checkDelayed(t *testing.T, name string, got [2][]fix.S17, in []fix.S17, latency int) {
+	t.Helper()
+	want := [2][]fix.S17{make([]fix.S17, latency), make([]fix.S17, latency)}
+	for _, s := range in[:len(in)-latency] {
+		want[0] = append(want[0], s)
+		want[1] = append(want[1], s.SMul(64))
+	}
+	for ch := range want {
+		if !slices.Equal(got[ch], want[ch]) {
+			t.Errorf("%s: channel %d = %v, want: %v", name, ch, got[ch], want[ch])
+		}
+	}
+}
+
+func TestDecoupled(t *testing.T) {
+	in := ramp(1000)
+	for _, c := range []struct {
+		name            string
+		block, headroom int
+		periods         []int
+	}{
+		{"regular", 16, 3, []int{16}},
+		{"irregular", 16, 0, []int{1, 40, 37, 5}},
+		{"big blocks", 64, 0, []int{3, 10}},
+		{"extra headroom", 8, 10, []int{12, 20}},
+	} {
+		opts := Options{BlockSize: c.block, Headroom: c.headroom, PeriodSize: slices.Max(c.periods)}
+		d := newDecoupled(twoWay(), S16, opts)
+		got := drive(t, c.name, d, in, c.periods)
+		// The output is late by the silence it starts with.
+		checkDelayed(t, c.name, got, in, (opts.headroom()-1)*c.block)
+	}
+}
+
+func TestDecoupledUnknownPeriod(t *testing.T) {
+	// Without a PeriodSize the device's periods can be much bigger than
+	// a block, like the Loopback's default of 512.
+	in := ramp(10000)
+	stats := &Stats{}
+	opts := Options{BlockSize: 32, Stats: stats}
+	d := newDecoupled(twoWay(), S16, opts)
+	got := drive(t, "unknown period", d, in, []int{512})
+	latency := (opts.headroom() - 1) * opts.BlockSize
+	if latency >= len(in) {
+		t.Fatalf("latency %d is longer than the input", latency)
+	}
+	checkDelayed(t, "unknown period", got, in, latency)
+	if c := stats.Read(); c.Underruns != 0 || c.Overruns != 0 {
+		t.Errorf("got %d underruns and %d overruns, want: none", c.Underruns, c.Overruns)
+	}
+}
+
+func TestPlayDecoupled(t *testing.T) {
+	// The loopback doesn't wait for the Ticker, so any of it might be
+	// silence, but the rest must be right.
+	lb := &Loopback{Frames: 1000, Periods: []int{7, 100, 33}}
+	stats := &Stats{}
+	play(t, fxp.Const{Val: 3}, Options{Backend: lb, BlockSize: 32, Stats: stats})
+	got := lb.Output()
+	if len(got) != 1 || len(got[0]) != 1000 {
+		t.Fatalf("got %d channels, want 1 with 1000 frames", len(got))
+	}
+	for i, s := range got[0] {
+		if s != 0 && s != 3 {
+			t.Errorf("out[0][%d] = %v, want: 3 or 0", i, s)
+		}
+	}
+	if got[0][0] != 3 {
+		t.Errorf("out[0][0] = %v, want: 3 (output should be ready before starting)", got[0][0])
+	}
+	c := stats.Read()
+	var silent int64
+	for _, s := range got[0] {
+		if s == 0 {
+			silent++
+		}
+	}
+	if c.Underruns != silent {
+		t.Errorf("Underruns = %d, want: %d", c.Underruns, silent)
+	}
+	if c.Frames != 1000 {
+		t.Errorf("Frames = %d, want: 1000", c.Frames)
+	}
+
+	lb = &Loopback{Frames: 300, Input: [][]fix.S17{ramp(300)}}
+	if err := Play(context.Background(), &sink{}, Options{Backend: lb, BlockSize: -1}); err == nil {
+		t.Error("Play accepted a negative block size")
+	}
+}
+
+// slow takes longer than it should.
+type slow struct{ d time.Duration }
+
+func (slow) Inputs() int    { return 0 }
+func (slow) Outputs() int   { return 1 }
+func (slow) String() string { return "slow" }
+func (s slow) Tick(_, out [][]fix.S17) {
+	time.Sleep(s.d)
+	clear(out[0])
+}
+
+func TestStats(t *testing.T) {
+	// 441 frames is 10ms.
+	lb := &Loopback{Frames: 441 * 3}
+	stats := &Stats{}
+	play(t, slow{20 * time.Millisecond}, Options{Backend: lb, PeriodSize: 441, Stats: stats})
+	c := stats.Read()
+	if c.Callbacks != 3 || c.Frames != 441*3 {
+		t.Errorf("got %d callbacks and %d frames, want: 3 and %d", c.Callbacks, c.Frames, 441*3)
+	}
+	if c.Late != 3 {
+		t.Errorf("Late = %d, want: 3", c.Late)
+	}
+	if c.Budget != 30*time.Millisecond {
+		t.Errorf("Budget = %v, want: 30ms", c.Budget)
+	}
+	if c.Load() < 2 || c.Peak < 2 {
+		t.Errorf("Load() = %v and Peak = %v, want both at least 2", c.Load(), c.Peak)
+	}
+
+	// Captured frames that don't fit are dropped.
+	stats = &Stats{}
+	d := newDecoupled(&sink{}, S16, Options{BlockSize: 16, Headroom: 2, Stats: stats})
+	d.callback(nil, make([]byte, 2*40), 40)
+	if c := stats.Read(); c.Overruns != 8 {
+		t.Errorf("Overruns = %d, want: 8", c.Overruns)
+	}
+}
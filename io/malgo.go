@@ -0,0 +1,151 @@
+package io
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gen2brain/malgo"
+)
+
+// Malgo is a Backend that uses the system's audio devices via malgo.
+type Malgo struct{}
+
+var _ Backend = Malgo{}
+
+// Devices lists the available capture and playback devices.
+func (Malgo) Devices() (capture, playback []Device, err error) {
+	mctx, err := initContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer freeContext(mctx)
+	c, err := mctx.Devices(malgo.Capture)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := mctx.Devices(malgo.Playback)
+	if err != nil {
+		return nil, nil, err
+	}
+	return devices(c), devices(p), nil
+}
+
+func (Malgo) Open(cfg StreamConfig, cb Callback) (Stream, error) {
+	var kind malgo.DeviceType
+	switch {
+	case cfg.CaptureChannels == 0 && cfg.PlaybackChannels == 0:
+		return nil, fmt.Errorf("stream with no channels")
+	case cfg.CaptureChannels == 0:
+		kind = malgo.Playback
+	case cfg.PlaybackChannels == 0:
+		kind = malgo.Capture
+	default:
+		kind = malgo.Duplex
+	}
+	var format malgo.FormatType
+	switch cfg.Format {
+	case U8:
+		format = malgo.FormatU8
+	case S16:
+		format = malgo.FormatS16
+	case F32:
+		format = malgo.FormatF32
+	default:
+		return nil, fmt.Errorf("unknown sample format %v", cfg.Format)
+	}
+
+	mctx, err := initContext()
+	if err != nil {
+		return nil, err
+	}
+	dcfg := malgo.DefaultDeviceConfig(kind)
+	dcfg.Capture.Format = format
+	dcfg.Capture.Channels = uint32(cfg.CaptureChannels)
+	dcfg.Playback.Format = format
+	dcfg.Playback.Channels = uint32(cfg.PlaybackChannels)
+	dcfg.SampleRate = uint32(cfg.SampleRate)
+	dcfg.PeriodSizeInFrames = uint32(max(0, cfg.PeriodSize))
+	if cfg.CaptureDevice != "" && cfg.CaptureChannels != 0 {
+		id, err := findDevice(mctx, malgo.Capture, cfg.CaptureDevice)
+		if err != nil {
+			freeContext(mctx)
+			return nil, err
+		}
+		dcfg.Capture.DeviceID = id.Pointer()
+	}
+	if cfg.PlaybackDevice != "" && cfg.PlaybackChannels != 0 {
+		id, err := findDevice(mctx, malgo.Playback, cfg.PlaybackDevice)
+		if err != nil {
+			freeContext(mctx)
+			return nil, err
+		}
+		dcfg.Playback.DeviceID = id.Pointer()
+	}
+	device, err := malgo.InitDevice(mctx.Context, dcfg, malgo.DeviceCallbacks{
+		Data: func(out, in []byte, framecount uint32) {
+			if framecount != 0 {
+				cb(out, in, int(framecount))
+			}
+		},
+	})
+	if err != nil {
+		freeContext(mctx)
+		return nil, err
+	}
+	return &malgoStream{mctx: mctx, device: device}, nil
+}
+
+type malgoStream struct {
+	mctx   *malgo.AllocatedContext
+	device *malgo.Device
+}
+
+func (s *malgoStream) Start() error { return s.device.Start() }
+
+func (s *malgoStream) Close() error {
+	s.device.Uninit()
+	freeContext(s.mctx)
+	return nil
+}
+
+func devices(infos []malgo.DeviceInfo) []Device {
+	out := make([]Device, len(infos))
+	for i, info := range infos {
+		out[i] = Device{
+			ID:      info.ID.String(),
+			Name:    info.Name(),
+			Default: info.IsDefault != 0,
+		}
+	}
+	return out
+}
+
+// findDevice looks up a device by ID or name.
+func findDevice(mctx *malgo.AllocatedContext, kind malgo.DeviceType, want string) (malgo.DeviceID, error) {
+	infos, err := mctx.Devices(kind)
+	if err != nil {
+		return malgo.DeviceID{}, err
+	}
+	for _, info := range infos {
+		if info.ID.String() == want || info.Name() == want {
+			return info.ID, nil
+		}
+	}
+	var names []string
+	for _, d := range devices(infos) {
+		names = append(names, d.String())
+	}
+	return malgo.DeviceID{}, fmt.Errorf("no device %q, have:\n%s", want, strings.Join(names, "\n"))
+}
+
+func initContext() (*malgo.AllocatedContext, error) {
+	return malgo.InitContext(nil, malgo.ContextConfig{}, func(msg string) {
+		fmt.Fprint(os.Stderr, msg)
+	})
+}
+
+func freeContext(mctx *malgo.AllocatedContext) {
+	mctx.Uninit()
+	mctx.Free()
+}
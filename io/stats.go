@@ -0,0 +1,109 @@
+package io
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Stats collects timing and xrun counts from Play, so you can see how close a
+// patch is to the limit. It's safe to Read while playing.
+type Stats struct {
+	callbacks, frames   atomic.Int64
+	underruns, overruns atomic.Int64
+	late                atomic.Int64
+	busy, budget        atomic.Int64
+	peak                atomic.Uint64
+}
+
+// Counters is a snapshot of Stats.
+type Counters struct {
+	// Callbacks and Frames count the device callbacks and the frames
+	// they asked for.
+	Callbacks, Frames int64
+	// Underruns is the number of output frames that weren't ready in
+	// time and were played as silence, and Overruns the number of
+	// captured frames that were dropped. Only a decoupled Play can
+	// notice these, otherwise they show up as Late.
+	Underruns, Overruns int64
+	// Late is the number of times the Ticker took longer to process some
+	// audio than it takes to play.
+	Late int64
+	// Busy is the total time spent in the Ticker and Budget is the total
+	// time the audio it processed lasts.
+	Busy, Budget time.Duration
+	// Peak is the highest load of a single block.
+	Peak float64
+}
+
+// Load is the fraction of the available time spent processing.
+func (c Counters) Load() float64 {
+	if c.Budget == 0 {
+		return 0
+	}
+	return float64(c.Busy) / float64(c.Budget)
+}
+
+func (c Counters) String() string {
+	return fmt.Sprintf("load %.0f%% (peak %.0f%%), %d late, %d underruns, %d overruns",
+		100*c.Load(), 100*c.Peak, c.Late, c.Underruns, c.Overruns)
+}
+
+// Read returns the current counts.
+func (s *Stats) Read() Counters {
+	return Counters{
+		Callbacks: s.callbacks.Load(),
+		Frames:    s.frames.Load(),
+		Underruns: s.underruns.Load(),
+		Overruns:  s.overruns.Load(),
+		Late:      s.late.Load(),
+		Busy:      time.Duration(s.busy.Load()),
+		Budget:    time.Duration(s.budget.Load()),
+		Peak:      math.Float64frombits(s.peak.Load()),
+	}
+}
+
+// The rest are called while playing and do nothing if s is nil.
+
+func (s *Stats) callback(frames int) {
+	if s == nil {
+		return
+	}
+	s.callbacks.Add(1)
+	s.frames.Add(int64(frames))
+}
+
+// processed records that it took since start to process frames of audio at
+// the sample rate.
+func (s *Stats) processed(start time.Time, frames, rate int) {
+	if s == nil {
+		return
+	}
+	busy := time.Since(start)
+	budget := time.Duration(frames) * time.Second / time.Duration(rate)
+	s.busy.Add(int64(busy))
+	s.budget.Add(int64(budget))
+	if busy > budget {
+		s.late.Add(1)
+	}
+	load := float64(busy) / float64(budget)
+	for {
+		old := s.peak.Load()
+		if load <= math.Float64frombits(old) || s.peak.CompareAndSwap(old, math.Float64bits(load)) {
+			break
+		}
+	}
+}
+
+func (s *Stats) underrun(frames int) {
+	if s != nil && frames > 0 {
+		s.underruns.Add(int64(frames))
+	}
+}
+
+func (s *Stats) overrun(frames int) {
+	if s != nil && frames > 0 {
+		s.overruns.Add(int64(frames))
+	}
+}
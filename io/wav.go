@@ -0,0 +1,454 @@
+package io
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	goio "io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+// SampleFormat is a way of encoding samples as bytes.
+type SampleFormat int
+
+const (
+	// U8 is unsigned 8 bit PCM, which holds an S17 exactly.
+	U8 SampleFormat = iota
+	// S16 is signed 16 bit little endian PCM.
+	S16
+	// F32 is 32 bit little endian IEEE float.
+	F32
+)
+
+func (f SampleFormat) String() string {
+	switch f {
+	case U8:
+		return "U8"
+	case S16:
+		return "S16"
+	case F32:
+		return "F32"
+	}
+	return fmt.Sprintf("SampleFormat(%d)", int(f))
+}
+
+// Size returns the number of bytes per sample.
+func (f SampleFormat) Size() int {
+	switch f {
+	case U8:
+		return 1
+	case S16:
+		return 2
+	}
+	return 4
+}
+
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavExtensible = 0xFFFE
+)
+
+// decode converts a single sample.
+func (f SampleFormat) decode(b []byte) fix.S17 {
+	switch f {
+	case U8:
+		return fix.S17(int8(b[0] ^ 0x80))
+	case S16:
+		return fix.S17(int16(binary.LittleEndian.Uint16(b)) >> 8)
+	}
+	return fix.FromFloat(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+}
+
+// encode appends a single sample to b.
+func (f SampleFormat) encode(b []byte, s fix.S17) []byte {
+	switch f {
+	case U8:
+		return append(b, byte(s)^0x80)
+	case S16:
+		return binary.LittleEndian.AppendUint16(b, uint16(int16(s)<<8))
+	}
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(fix.Float[float32](s)))
+}
+
+// WAVReader decodes a WAV file.
+type WAVReader struct {
+	Channels   int
+	SampleRate int
+	Format     SampleFormat
+	// Quantization is how S16 and F32 samples are reduced to S17. Set it
+	// before the first Read.
+	Quantization fix.Quantization
+
+	qs        []fix.Quantizer
+	r         goio.Reader
+	remaining int // bytes left in the data chunk
+	buf       []byte
+}
+
+// NewWAVReader reads the header of a WAV file, leaving r at the start of the
+// samples.
+func NewWAVReader(r goio.Reader) (*WAVReader, error) {
+	var riff [12]byte
+	if _, err := goio.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("reading RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	w := &WAVReader{r: r}
+	gotFmt := false
+	for {
+		var hdr [8]byte
+		if _, err := goio.ReadFull(r, hdr[:]); err != nil {
+			return nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		id, size := string(hdr[:4]), int(binary.LittleEndian.Uint32(hdr[4:]))
+		switch id {
+		case "fmt ":
+			body := make([]byte, size+size%2)
+			if _, err := goio.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("reading fmt chunk: %w", err)
+			}
+			if err := w.parseFmt(body[:size]); err != nil {
+				return nil, err
+			}
+			gotFmt = true
+		case "data":
+			if !gotFmt {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			w.remaining = size
+			return w, nil
+		default:
+			// Skip chunks we don't care about, which are padded
+			// to an even length.
+			if _, err := goio.CopyN(goio.Discard, r, int64(size+size%2)); err != nil {
+				return nil, fmt.Errorf("skipping %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func (w *WAVReader) parseFmt(b []byte) error {
+	if len(b) < 16 {
+		return fmt.Errorf("fmt chunk too short: %d bytes", len(b))
+	}
+	tag := binary.LittleEndian.Uint16(b[0:])
+	w.Channels = int(binary.LittleEndian.Uint16(b[2:]))
+	w.SampleRate = int(binary.LittleEndian.Uint32(b[4:]))
+	bits := binary.LittleEndian.Uint16(b[14:])
+	if tag == wavExtensible {
+		if len(b) < 26 {
+			return fmt.Errorf("extensible fmt chunk too short: %d bytes", len(b))
+		}
+		// The format is the start of the sub format GUID.
+		tag = binary.LittleEndian.Uint16(b[24:])
+	}
+	switch {
+	case tag == wavPCM && bits == 8:
+		w.Format = U8
+	case tag == wavPCM && bits == 16:
+		w.Format = S16
+	case tag == wavFloat && bits == 32:
+		w.Format = F32
+	default:
+		return fmt.Errorf("unsupported WAV format %d with %d bits", tag, bits)
+	}
+	if w.Channels == 0 {
+		return errors.New("no channels")
+	}
+	return nil
+}
+
+// Read decodes up to len(block[0]) frames into block, which must have a
+// slice for each channel. It returns the number of frames read, and io.EOF
+// when there are none left.
+func (w *WAVReader) Read(block [][]fix.S17) (int, error) {
+	if len(block) != w.Channels {
+		return 0, fmt.Errorf("got %d channels, want: %d", len(block), w.Channels)
+	}
+	frame := w.Channels * w.Format.Size()
+	n := min(len(block[0]), w.remaining/frame)
+	if n == 0 {
+		return 0, goio.EOF
+	}
+	if cap(w.buf) < n*frame {
+		w.buf = make([]byte, n*frame)
+	}
+	buf := w.buf[:n*frame]
+	if _, err := goio.ReadFull(w.r, buf); err != nil {
+		return 0, fmt.Errorf("reading samples: %w", err)
+	}
+	w.remaining -= len(buf)
+	if w.qs == nil {
+		w.qs = quantizers(w.Quantization, w.Channels)
+	}
+	w.Format.deinterleave(block, buf, n, w.qs)
+	return n, nil
+}
+
+// ReadAll decodes all of the remaining samples.
+func (w *WAVReader) ReadAll() ([][]fix.S17, error) {
+	out := make([][]fix.S17, w.Channels)
+	block := make([][]fix.S17, w.Channels)
+	for i := range block {
+		block[i] = make([]fix.S17, 4096)
+	}
+	for {
+		n, err := w.Read(block)
+		if err == goio.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range out {
+			out[i] = append(out[i], block[i][:n]...)
+		}
+	}
+}
+
+// WAVWriter encodes a WAV file. It implements render.Sink.
+type WAVWriter struct {
+	channels   int
+	sampleRate int
+	format     SampleFormat
+
+	ws     goio.WriteSeeker
+	w      *bufio.Writer
+	closer goio.Closer // set if we own the file
+	frames int
+	buf    []byte
+}
+
+// NewWAVWriter writes a WAV header to ws and returns a WAVWriter ready for
+// samples. The header is completed by Close, which is why ws needs to be
+// seekable.
+func NewWAVWriter(ws goio.WriteSeeker, channels, sampleRate int, format SampleFormat) (*WAVWriter, error) {
+	if format < U8 || format > F32 {
+		return nil, fmt.Errorf("unknown sample format %v", format)
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("%d channels", channels)
+	}
+	w := &WAVWriter{
+		channels:   channels,
+		sampleRate: sampleRate,
+		format:     format,
+		ws:         ws,
+		w:          bufio.NewWriter(ws),
+	}
+	if _, err := w.w.Write(w.header()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CreateWAV creates a WAV file at path. Closing the WAVWriter closes the file.
+func CreateWAV(path string, channels, sampleRate int, format SampleFormat) (*WAVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWAVWriter(f, channels, sampleRate, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// header returns the WAV header for the frames written so far.
+func (w *WAVWriter) header() []byte {
+	size := w.format.Size()
+	dataSize := w.frames * w.channels * size
+	tag, fmtSize := uint16(wavPCM), 16
+	if w.format == F32 {
+		// Non-PCM formats have an extra (empty) field and need a
+		// fact chunk.
+		tag, fmtSize = wavFloat, 18
+	}
+	b := make([]byte, 0, 58)
+	b = append(b, "RIFF"...)
+	riffSize := 4 + 8 + fmtSize + 8 + dataSize + dataSize%2
+	if w.format == F32 {
+		riffSize += 12
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(riffSize))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(fmtSize))
+	b = binary.LittleEndian.AppendUint16(b, tag)
+	b = binary.LittleEndian.AppendUint16(b, uint16(w.channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(w.sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(w.sampleRate*w.channels*size))
+	b = binary.LittleEndian.AppendUint16(b, uint16(w.channels*size))
+	b = binary.LittleEndian.AppendUint16(b, uint16(8*size))
+	if w.format == F32 {
+		b = binary.LittleEndian.AppendUint16(b, 0)
+		b = append(b, "fact"...)
+		b = binary.LittleEndian.AppendUint32(b, 4)
+		b = binary.LittleEndian.AppendUint32(b, uint32(w.frames))
+	}
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(dataSize))
+	return b
+}
+
+// Write encodes a block of audio, which must have a slice for each channel.
+func (w *WAVWriter) Write(block [][]fix.S17) error {
+	if len(block) != w.channels {
+		return fmt.Errorf("got %d channels, want: %d", len(block), w.channels)
+	}
+	if len(block) == 0 {
+		return nil
+	}
+	b := w.buf[:0]
+	for i := range block[0] {
+		for _, c := range block {
+			b = w.format.encode(b, c[i])
+		}
+	}
+	w.buf = b
+	w.frames += len(block[0])
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Close finishes the file by filling in the sizes in the header.
+func (w *WAVWriter) Close() error {
+	var errs []error
+	if (w.frames*w.channels*w.format.Size())%2 != 0 {
+		// The data chunk needs to be padded to an even length.
+		errs = append(errs, w.w.WriteByte(0))
+	}
+	errs = append(errs, w.w.Flush())
+	if _, err := w.ws.Seek(0, goio.SeekStart); err != nil {
+		errs = append(errs, err)
+	} else {
+		_, err := w.ws.Write(w.header())
+		errs = append(errs, err)
+	}
+	if w.closer != nil {
+		errs = append(errs, w.closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Player is a Ticker that plays a recording from memory. It has no inputs
+// and an output for each channel of the recording.
+type Player struct {
+	samples [][]fix.S17
+	pos     int
+	// Loop makes the Player start again from the beginning when it gets
+	// to the end, rather than going quiet.
+	Loop bool
+}
+
+var _ fxp.Ticker = &Player{}
+
+// NewPlayer returns a Player for the provided audio, one slice per channel,
+// or an error if the channels aren't all the same length.
+func NewPlayer(samples [][]fix.S17, loop bool) (*Player, error) {
+	for i, s := range samples {
+		if len(s) != len(samples[0]) {
+			return nil, fmt.Errorf("channel %d has %d samples, channel 0 has %d", i, len(s), len(samples[0]))
+		}
+	}
+	return &Player{samples: samples, Loop: loop}, nil
+}
+
+// LoadWAV reads a whole WAV file into a Player.
+func LoadWAV(path string, loop bool) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewWAVReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	samples, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewPlayer(samples, loop)
+}
+
+func (p *Player) Inputs() int    { return 0 }
+func (p *Player) Outputs() int   { return len(p.samples) }
+func (p *Player) String() string { return fmt.Sprintf("Player(%d)", p.Outputs()) }
+
+func (p *Player) Tick(_, outputs [][]fix.S17) {
+	if len(outputs) == 0 {
+		return
+	}
+	length := len(p.samples[0])
+	for done := 0; done < len(outputs[0]); {
+		if p.pos >= length {
+			if !p.Loop || length == 0 {
+				for _, o := range outputs {
+					clear(o[done:])
+				}
+				return
+			}
+			p.pos = 0
+		}
+		// The channels are all the same length, so they copy the same
+		// amount.
+		n := min(len(outputs[0])-done, length-p.pos)
+		for i, o := range outputs {
+			copy(o[done:done+n], p.samples[i][p.pos:])
+		}
+		done += n
+		p.pos += n
+	}
+}
+
+// Recorder is a Ticker that passes its inputs straight through and also
+// writes them to a WAVWriter. Writing happens inside Tick, so it is best
+// suited to offline rendering. Errors are kept, see Err.
+type Recorder struct {
+	w *WAVWriter
+
+	mu  sync.Mutex
+	err error
+}
+
+var _ fxp.Ticker = &Recorder{}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w *WAVWriter) *Recorder {
+	return &Recorder{w: w}
+}
+
+func (r *Recorder) Inputs() int    { return r.w.channels }
+func (r *Recorder) Outputs() int   { return r.w.channels }
+func (r *Recorder) String() string { return fmt.Sprintf("Recorder(%d)", r.w.channels) }
+
+func (r *Recorder) Tick(inputs, outputs [][]fix.S17) {
+	for i, in := range inputs {
+		copy(outputs[i], in)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.w.Write(inputs)
+	}
+}
+
+// Err returns the first error encountered while writing, if any. Once there
+// has been an error, nothing more is written.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
@@ -0,0 +1,223 @@
+package io
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	goio "io"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// seekBuf is an in memory io.WriteSeeker.
+type seekBuf struct {
+	b   []byte
+	pos int
+}
+
+func (s *seekBuf) Write(p []byte) (int, error) {
+	if need := s.pos + len(p); need > len(s.b) {
+		s.b = append(s.b, make([]byte, need-len(s.b))...)
+	}
+	copy(s.b[s.pos:], p)
+	s.pos += len(p)
+	return len(p), nil
+}
+
+func (s *seekBuf) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case goio.SeekStart:
+		s.pos = int(offset)
+	case goio.SeekCurrent:
+		s.pos += int(offset)
+	case goio.SeekEnd:
+		s.pos = len(s.b) + int(offset)
+	}
+	return int64(s.pos), nil
+}
+
+func allS17s() []fix.S17 {
+	var out []fix.S17
+	for i := int(fix.MinS17); i <= int(fix.MaxS17); i++ {
+		out = append(out, fix.S17(i))
+	}
+	return out
+}
+
+func TestWAVRoundTrip(t *testing.T) {
+	all := allS17s()
+	rev := make([]fix.S17, len(all))
+	for i, s := range all {
+		rev[len(rev)-1-i] = s
+	}
+	for _, f := range []SampleFormat{U8, S16, F32} {
+		for _, chans := range [][][]fix.S17{
+			{all},
+			{all, rev},
+			{all[:3]}, // odd length data chunk
+		} {
+			var buf seekBuf
+			w, err := NewWAVWriter(&buf, len(chans), 8000, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Write in a couple of blocks.
+			n := len(chans[0]) / 2
+			first, second := make([][]fix.S17, len(chans)), make([][]fix.S17, len(chans))
+			for i, c := range chans {
+				first[i], second[i] = c[:n], c[n:]
+			}
+			if err := errors.Join(w.Write(first), w.Write(second), w.Close()); err != nil {
+				t.Fatal(err)
+			}
+			if len(buf.b)%2 != 0 {
+				t.Errorf("%v: odd file length %d", f, len(buf.b))
+			}
+
+			r, err := NewWAVReader(bytes.NewReader(buf.b))
+			if err != nil {
+				t.Fatalf("%v: %v", f, err)
+			}
+			if r.Channels != len(chans) || r.SampleRate != 8000 || r.Format != f {
+				t.Errorf("%v: got %d channels at %d in %v", f, r.Channels, r.SampleRate, r.Format)
+			}
+			got, err := r.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for c := range chans {
+				if !slices.Equal(got[c], chans[c]) {
+					t.Errorf("%v, %d channels: channel %d = %v, want: %v", f, len(chans), c, got[c], chans[c])
+				}
+			}
+		}
+	}
+}
+
+func TestWAVReaderQuantization(t *testing.T) {
+	// A float file holding a constant between two S17s.
+	const n, lsbs = 4000, 10.3
+	var buf seekBuf
+	w, err := NewWAVWriter(&buf, 1, 8000, F32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(w.Write([][]fix.S17{make([]fix.S17, n)}), w.Close()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.b[len(buf.b)-4*n:]
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(lsbs/128))
+	}
+
+	for _, c := range []struct {
+		q      fix.Quantization
+		lo, hi float64
+	}{
+		{fix.Truncate, 10, 10},
+		{fix.Round, 10, 10},
+		{fix.Dither, 10.2, 10.4},
+	} {
+		r, err := NewWAVReader(bytes.NewReader(buf.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Quantization = c.q
+		got, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := 0.0
+		for _, s := range got[0] {
+			sum += float64(s)
+		}
+		if mean := sum / n; mean < c.lo || mean > c.hi {
+			t.Errorf("%v: mean = %v, want: between %v and %v", c.q, mean, c.lo, c.hi)
+		}
+	}
+}
+
+func TestWAVReaderErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"not riff", "RIFX\x00\x00\x00\x00WAVE"},
+		{"no data", "RIFF\x00\x00\x00\x00WAVE"},
+		{"data first", "RIFF\x00\x00\x00\x00WAVEdata\x00\x00\x00\x00"},
+		{"bad format", "RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x02\x00\x01\x00\x40\x1f\x00\x00\x40\x1f\x00\x00\x01\x00\x08\x00"},
+	} {
+		if _, err := NewWAVReader(bytes.NewReader([]byte(c.data))); err == nil {
+			t.Errorf("%s: NewWAVReader succeeded", c.name)
+		}
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	samples := [][]fix.S17{{1, 2, 3}, {4, 5, 6}}
+	for _, c := range []struct {
+		loop bool
+		want [][]fix.S17
+	}{
+		{false, [][]fix.S17{{1, 2, 3, 0, 0, 0, 0, 0}, {4, 5, 6, 0, 0, 0, 0, 0}}},
+		{true, [][]fix.S17{{1, 2, 3, 1, 2, 3, 1, 2}, {4, 5, 6, 4, 5, 6, 4, 5}}},
+	} {
+		p, err := NewPlayer(samples, c.loop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := [][]fix.S17{make([]fix.S17, 8), make([]fix.S17, 8)}
+		// Tick in two uneven blocks.
+		p.Tick(nil, [][]fix.S17{got[0][:2], got[1][:2]})
+		p.Tick(nil, [][]fix.S17{got[0][2:], got[1][2:]})
+		for i := range got {
+			if !slices.Equal(got[i], c.want[i]) {
+				t.Errorf("loop %t: channel %d = %v, want: %v", c.loop, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestPlayerRagged(t *testing.T) {
+	for _, samples := range [][][]fix.S17{
+		{{1, 2, 3}, {4, 5}},
+		{{1, 2}, {4, 5, 6}},
+		{{}, {1}},
+	} {
+		if _, err := NewPlayer(samples, true); err == nil {
+			t.Errorf("NewPlayer(%v) succeeded", samples)
+		}
+	}
+}
+
+func TestRecorderAndLoadWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w, err := CreateWAV(path, 1, 44100, U8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder(w)
+	in := [][]fix.S17{{10, -10, 127, -128}}
+	out := [][]fix.S17{make([]fix.S17, 4)}
+	r.Tick(in, out)
+	if !slices.Equal(out[0], in[0]) {
+		t.Errorf("Recorder output %v, want: %v", out[0], in[0])
+	}
+	if err := errors.Join(r.Err(), w.Close()); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadWAV(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := [][]fix.S17{make([]fix.S17, 4)}
+	p.Tick(nil, got)
+	if !slices.Equal(got[0], in[0]) {
+		t.Errorf("LoadWAV played %v, want: %v", got[0], in[0])
+	}
+}
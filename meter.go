@@ -0,0 +1,204 @@
+package fxp
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// Saturation counts the clipping in and around one node of a metered Graph,
+// see Graph.Meter.
+type Saturation struct {
+	Node Node
+	Name string
+	// Ops is the number of fix operations that saturated while the node
+	// was ticking. They are only counted in builds with the fxpsat tag.
+	Ops uint64
+	// FullScale counts the samples at the limits of the sample type on
+	// each of the node's outputs, which is what clipping further up
+	// usually looks like.
+	FullScale []uint64
+	// Mixed counts the samples that saturated adding up each connection
+	// into the node.
+	Mixed []EdgeSaturation
+}
+
+// EdgeSaturation counts the samples that saturated when a connection was
+// added to the ones before it on the same input. The first connection into an
+// input is copied rather than added, so it never saturates.
+type EdgeSaturation struct {
+	From          Node
+	Output, Input int
+	Count         uint64
+}
+
+func (e EdgeSaturation) String() string {
+	return fmt.Sprintf("%d:%d->%d: %d", e.From, e.Output, e.Input, e.Count)
+}
+
+// Total is the number of saturated operations and samples, not counting
+// samples at full scale which might be intentional.
+func (s Saturation) Total() uint64 {
+	total := s.Ops
+	for _, m := range s.Mixed {
+		total += m.Count
+	}
+	return total
+}
+
+func (s Saturation) String() string {
+	return fmt.Sprintf("%d %s: %d ops, full scale %v, mixed %v", s.Node, s.Name, s.Ops, s.FullScale, s.Mixed)
+}
+
+// meter holds the counts for a Saturation, apart from the mixing which is
+// counted on the edges.
+type meter struct {
+	ops       atomic.Uint64
+	fullScale []atomic.Uint64
+}
+
+func newMeter(outputs int) *meter {
+	return &meter{fullScale: make([]atomic.Uint64, outputs)}
+}
+
+func readMeter[T Sample](m *meter, n Node, name string, srcs [][]edge[T]) Saturation {
+	s := Saturation{
+		Node:      n,
+		Name:      name,
+		Ops:       m.ops.Load(),
+		FullScale: make([]uint64, len(m.fullScale)),
+		Mixed:     []EdgeSaturation{},
+	}
+	for i := range m.fullScale {
+		s.FullScale[i] = m.fullScale[i].Load()
+	}
+	for i, edges := range srcs {
+		for _, e := range edges {
+			s.Mixed = append(s.Mixed, EdgeSaturation{
+				From:   e.node,
+				Output: e.channel,
+				Input:  i,
+				Count:  e.mixed.Load(),
+			})
+		}
+	}
+	return s
+}
+
+// Meter turns on counting saturation in the graph, per node and per
+// connection. It costs a little every block so it's off by default, and
+// counting the fix operations that saturate inside each node also needs the
+// fxpsat build tag. It should not be called concurrently with Tick.
+//
+// The fix operations are counted for the whole process, so in fxpsat builds
+// Parallel runs its Tickers one at a time, and the counts are only right if
+// nothing else ticks on another goroutine at the same time.
+func (g *GraphOf[T]) Meter() {
+	for _, n := range g.nodes {
+		if n.meter == nil {
+			n.meter = newMeter(len(n.outs))
+		}
+		for _, srcs := range n.srcs {
+			meterEdges(srcs)
+		}
+	}
+	for _, srcs := range g.outs {
+		meterEdges(srcs)
+	}
+	if g.outMeter == nil {
+		g.outMeter = newMeter(0)
+	}
+}
+
+// meterEdges gives any edges that don't have one a counter.
+func meterEdges[T Sample](srcs []edge[T]) {
+	for i := range srcs {
+		if srcs[i].mixed == nil {
+			srcs[i].mixed = new(atomic.Uint64)
+		}
+	}
+}
+
+// Saturation returns the counts so far for each node, followed by one for
+// GraphOutputs, or nil if the graph isn't metered. It's safe to call while the
+// graph is ticking.
+func (g *GraphOf[T]) Saturation() []Saturation {
+	if g.outMeter == nil {
+		return nil
+	}
+	out := make([]Saturation, 0, len(g.nodes)+1)
+	for i, n := range g.nodes {
+		out = append(out, readMeter(n.meter, Node(i), n.String(), n.srcs))
+	}
+	return append(out, readMeter(g.outMeter, GraphOutputs, g.name(GraphOutputs), g.outs))
+}
+
+// SaturatedOps returns the number of fix operations that have saturated while
+// the graph was ticking since it was metered, mixing included. Like the Ops for
+// each node they're only counted with the fxpsat build tag.
+func (g *GraphOf[T]) SaturatedOps() uint64 {
+	return g.ops.Load()
+}
+
+// tickMetered ticks a node, counting what it saturates.
+func tickMetered[T Sample](n *graphNode[T]) {
+	before := fix.Saturations()
+	n.Tick(n.ins, n.outs)
+	n.meter.ops.Add(fix.Saturations() - before)
+	for i, out := range n.outs {
+		n.meter.fullScale[i].Add(uint64(fullScale(out)))
+	}
+}
+
+// mixCount is mix, but also returns the number of samples that saturated.
+func mixCount[T Sample](dst, src []T) int {
+	n := 0
+	switch d := any(dst).(type) {
+	case []fix.S17:
+		for i, s := range any(src).([]fix.S17) {
+			if v := int(d[i]) + int(s); v < int(fix.MinS17) || v > int(fix.MaxS17) {
+				n++
+			}
+			d[i] = d[i].SAdd(s)
+		}
+	case []fix.S115:
+		for i, s := range any(src).([]fix.S115) {
+			if v := int(d[i]) + int(s); v < int(fix.MinS115) || v > int(fix.MaxS115) {
+				n++
+			}
+			d[i] = d[i].SAdd(s)
+		}
+	default:
+		// Floats don't saturate.
+		mix(dst, src)
+	}
+	return n
+}
+
+// fullScale returns the number of samples at the limits of their type. Floats
+// don't have any, so it counts those that would clip when converted.
+func fullScale[T Sample](s []T) int {
+	n := 0
+	switch s := any(s).(type) {
+	case []fix.S17:
+		for _, x := range s {
+			if x == fix.MinS17 || x == fix.MaxS17 {
+				n++
+			}
+		}
+	case []fix.S115:
+		for _, x := range s {
+			if x == fix.MinS115 || x == fix.MaxS115 {
+				n++
+			}
+		}
+	case []float32:
+		for _, x := range s {
+			if x <= -1 || x >= 1 {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -2,23 +2,57 @@
 package osc
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/pfcm/fxp"
 	"github.com/pfcm/fxp/fix"
 	"github.com/pfcm/fxp/interp"
+	"github.com/pfcm/fxp/patch"
 )
 
+func init() {
+	patch.Register("osc.Sine", func(env patch.Env, p patch.Params) (fxp.Ticker, error) {
+		lowest, err := p.Int("lowest", 0)
+		if err != nil {
+			return nil, err
+		}
+		name, err := p.String("interp", "")
+		if err != nil {
+			return nil, err
+		}
+		t := Sine(env.SampleRate, lowest)
+		if name == "" {
+			return t, nil
+		}
+		if t.Interp, err = interp.Parse(name); err != nil {
+			return nil, err
+		}
+		if _, ok := t.Interp.(*interp.Allpass); ok {
+			return nil, fmt.Errorf("can't use %v interpolation in a wavetable", t.Interp)
+		}
+		return t, nil
+	})
+}
+
 // Table is a wavetable oscillator. It receives a single input, which is the
 // note to play, and has one output, an appropriate block of samples.
-// The note wouldn't make sense in a fix.S17; it is reintepreted as a
-// fix.U62 encoding a (fractional) midi note, offset by the Lowest field.
-// TODO: we may want more fractional components.
+// The note wouldn't make sense in a fix.S17, so it carries the bits of a
+// fix.U62 (see fix.U62.S17Bits) encoding a (fractional) midi note, offset by
+// the Lowest field.
 type Table struct {
-	tab        []fix.S17
-	phase      float32
-	samplerate float32
-	Lowest     int
+	tab []fix.S17
+	// phase and step are positions in the table.
+	phase, step fix.U1616
+	note        fix.U62 // that step is for
+	samplerate  int
+	Lowest      int
+	// Interp interpolates between the samples in the table, if it's nil
+	// it uses interp.L. It can't be an interp.Allpass, which needs every
+	// sample in turn rather than skipping through the table.
+	Interp interp.Interpolator
+	// x holds the samples for Interp.
+	x [interp.MaxTaps]fix.S17
 }
 
 var _ fxp.Ticker = &Table{}
@@ -28,15 +62,38 @@ func (t *Table) Outputs() int   { return 1 }
 func (t *Table) String() string { return "osc.Table" }
 
 func (t *Table) Tick(in, out [][]fix.S17) {
-	for i, step := range in[0] {
-		j, k := int(t.phase), int(t.phase+1)%len(t.tab)
-		c := t.phase - float32(j)
-		out[0][i] = interp.L(t.tab[j], t.tab[k], fix.FromFloat(c))
-		t.phase += t.step(fix.U62(step))
-		for t.phase >= float32(len(t.tab)) {
-			t.phase -= float32(len(t.tab))
+	end := fix.U1616(len(t.tab)) << 16
+	for i, s := range in[0] {
+		if note := fix.U62FromS17Bits(s); note != t.note || t.step == 0 {
+			t.note, t.step = note, t.noteStep(note)
+		}
+		if t.Interp != nil {
+			out[0][i] = t.interpolate()
+		} else {
+			j := int(t.phase >> 16)
+			k := (j + 1) % len(t.tab)
+			// The top 7 bits of the phase's fractional part.
+			c := fix.S17(t.phase >> 9 & 0x7F)
+			out[0][i] = interp.L(t.tab[j], t.tab[k], c)
 		}
+		t.phase += t.step
+		for t.phase >= end {
+			t.phase -= end
+		}
+	}
+}
+
+// interpolate uses Interp to find the sample at the current phase.
+func (t *Table) interpolate() fix.S17 {
+	n := len(t.tab)
+	x := t.x[:t.Interp.Taps()]
+	// Go round the table as many times as it takes, it might be
+	// shorter than the interpolator.
+	j := int(t.phase>>16) - t.Interp.Offset()
+	for k := range x {
+		x[k] = t.tab[((j+k)%n+n)%n]
 	}
+	return t.Interp.At(x, fix.U016(t.phase))
 }
 
 // Sine returns a Table initialised with a sensible sine wave.
@@ -49,24 +106,29 @@ func Sine(samplerate float32, lowest int) *Table {
 	}
 	return &Table{
 		tab:        table,
-		samplerate: samplerate,
+		samplerate: int(samplerate),
 		Lowest:     lowest,
 	}
 }
 
-// step calculates a step value to achieve the provided midi note value as closely as
-// possible.
-func (t Table) step(note fix.U62) float32 {
-	// first turn the note into a frequency.
-	// TODO: lookup table?
-	n := float64(t.Lowest) + fix.U62ToFloat[float64](note)
-	freq := math.Pow(2.0, (n-69)/12) * 440
-	// freq is essentially in tables per second, calculate how many samples
-	// from the table we need per second.
-	tableSamplesPerSecond := float64(len(t.tab)) * freq
-	// figure out the seconds per output sample
-	secondsPerOutputSample := 1.0 / t.samplerate
-	// table samples per output sample is therefore the rate we need to advance
-	// through the table.
-	return float32(tableSamplesPerSecond) * secondsPerOutputSample
+// noteStep calculates a step value to achieve the provided midi note value as
+// closely as possible.
+func (t *Table) noteStep(note fix.U62) fix.U1616 {
+	// The frequency is 440 * 2^((n - 69) / 12), with n in semitones.
+	semis := int64(t.Lowest-69)<<16 + int64(note)<<14
+	ratio := fix.Exp2(fix.S1616(min(max(semis/12, int64(fix.MinS1616)), int64(fix.MaxS1616))))
+	return t.freqStep(440 * int64(ratio))
+}
+
+// MakeStep returns the step through the table per output sample that plays
+// the provided frequency, in Hz.
+func (t *Table) MakeStep(freq float32) fix.U1616 {
+	return t.freqStep(int64(fix.U1616FromFloat(freq)))
+}
+
+// freqStep is MakeStep for a frequency with 16 fractional bits.
+func (t *Table) freqStep(freq int64) fix.U1616 {
+	// freq is essentially in tables per second, so the step is the
+	// number of table samples per second divided by the sample rate.
+	return fix.U1616(min(int64(len(t.tab))*freq/int64(t.samplerate), int64(fix.MaxU1616)))
 }
@@ -1,9 +1,11 @@
 package osc
 
 import (
+	"math"
 	"testing"
 
 	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/interp"
 )
 
 func TestMakeStep(t *testing.T) {
@@ -15,7 +17,84 @@ func TestMakeStep(t *testing.T) {
 		1024,
 		40000,
 	} {
+		want := 128 * float64(f) / 44100
+		got := fix.U1616ToFloat[float64](tab.MakeStep(f))
+		if math.Abs(got-want) > 1.0/(1<<16) {
+			t.Errorf("MakeStep(%v) = %v, want: %v", f, got, want)
+		}
+	}
+	// It should agree with the steps for notes.
+	tab.Lowest = 69
+	if got, want := tab.MakeStep(440), tab.noteStep(0); got != want {
+		t.Errorf("MakeStep(440) = %v, want noteStep(0): %v", got, want)
+	}
+}
+
+func TestNoteStep(t *testing.T) {
+	tab := Sine(44100, 40)
+	for n := 0; n <= int(fix.MaxU62); n++ {
+		note := fix.U62(n)
+		freq := 440 * math.Pow(2, (40+fix.U62ToFloat[float64](note)-69)/12)
+		want := 128 * freq / 44100
+		got := fix.U1616ToFloat[float64](tab.noteStep(note))
+		if math.Abs(got-want) > want*1e-4+1.0/(1<<16) {
+			t.Errorf("noteStep(%v) = %v, want: %v", note, got, want)
+		}
+	}
+}
 
-		t.Error(tab.MakeStep(f))
+func TestTablePitch(t *testing.T) {
+	// A440 for a second should cross zero going up 440 times.
+	tab := Sine(44100, 60)
+	in := make([]fix.S17, 44100)
+	for i := range in {
+		in[i] = fix.U62FromFloat(float32(9)).S17Bits()
+	}
+	out := [][]fix.S17{make([]fix.S17, len(in))}
+	tab.Tick([][]fix.S17{in}, out)
+	crossings := 0
+	for i := 1; i < len(in); i++ {
+		if out[0][i-1] < 0 && out[0][i] >= 0 {
+			crossings++
+		}
+	}
+	if crossings < 439 || crossings > 441 {
+		t.Errorf("got %d upwards zero crossings, want: 440", crossings)
+	}
+}
+
+func TestTableInterp(t *testing.T) {
+	// A slow note should be a smooth sine whichever way it's
+	// interpolated, the better ones more so.
+	note := fix.U62FromFloat(float32(0)).S17Bits()
+	for _, c := range []struct {
+		name string
+		tol  float64
+	}{
+		{"linear", 2},
+		{"hermite", 1.5},
+		{"lagrange", 1.5},
+		{"sinc8", 1.5},
+	} {
+		tab := Sine(44100, 60)
+		in, err := interp.Parse(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tab.Interp = in
+		notes := make([]fix.S17, 1000)
+		for i := range notes {
+			notes[i] = note
+		}
+		out := [][]fix.S17{make([]fix.S17, len(notes))}
+		tab.Tick([][]fix.S17{notes}, out)
+		step := fix.U1616ToFloat[float64](tab.noteStep(0))
+		for i, got := range out[0] {
+			want := min(128*math.Sin(2*math.Pi*float64(i)*step/128), 127)
+			if d := math.Abs(float64(got) - want); d > c.tol {
+				t.Errorf("%s: out[%d] = %v, want: %v", c.name, i, float64(got), want)
+				break
+			}
+		}
 	}
 }
@@ -0,0 +1,100 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+// The Tickers from package fxp are registered here, as fxp can't import
+// this package.
+func init() {
+	Register("Const", func(_ Env, p Params) (fxp.Ticker, error) {
+		v, err := p.Float("value", 0)
+		return fxp.Const{Val: fix.FromFloat(v)}, err
+	})
+	// Note is a Const holding a fix.U62 midi note, as expected by
+	// oscillators.
+	Register("Note", func(_ Env, p Params) (fxp.Ticker, error) {
+		n, err := p.Float("note", 0)
+		return fxp.Const{Val: fix.U62FromFloat(n).S17Bits()}, err
+	})
+	Register("Scale", func(_ Env, p Params) (fxp.Ticker, error) {
+		mul, err1 := p.Float("mul", fix.Float[float64](fix.MaxS17))
+		shift, err2 := p.Float("shift", 0)
+		return fxp.Scale{Mul: fix.FromFloat(mul), Shift: fix.FromFloat(shift)}, errors.Join(err1, err2)
+	})
+	Register("Mixer", func(_ Env, p Params) (fxp.Ticker, error) {
+		gs, err := p.Floats("gains")
+		if err != nil {
+			return nil, err
+		}
+		if len(gs) == 0 {
+			return nil, fmt.Errorf("no gains")
+		}
+		m := fxp.Mixer{Gains: make([]fix.S17, len(gs))}
+		for i, g := range gs {
+			m.Gains[i] = fix.FromFloat(g)
+		}
+		return m, nil
+	})
+	Register("Sum", func(_ Env, p Params) (fxp.Ticker, error) {
+		n, err := channels(p)
+		if err != nil {
+			return nil, err
+		}
+		return fxp.Sum(n), nil
+	})
+	Register("Mult", func(_ Env, p Params) (fxp.Ticker, error) {
+		n, err := channels(p)
+		if err != nil {
+			return nil, err
+		}
+		return fxp.Mult{N: n}, nil
+	})
+	Register("Noop", func(_ Env, p Params) (fxp.Ticker, error) {
+		n, err := channels(p)
+		if err != nil {
+			return nil, err
+		}
+		return fxp.Noop{N: n}, nil
+	})
+	Register("Amp", func(Env, Params) (fxp.Ticker, error) {
+		return fxp.Amp{}, nil
+	})
+	Register("Requantize", func(_ Env, p Params) (fxp.Ticker, error) {
+		n, err1 := channels(p)
+		bits, err2 := p.Int("bits", 8)
+		qs, err3 := p.String("quantization", fix.Truncate.String())
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, err
+		}
+		q, err := fix.ParseQuantization(qs)
+		if err != nil {
+			return nil, fmt.Errorf("param \"quantization\": %w", err)
+		}
+		return fxp.NewRequantize(n, bits, q), nil
+	})
+	Register("Every", func(env Env, p Params) (fxp.Ticker, error) {
+		v, err1 := p.Float("value", fix.Float[float64](fix.MaxS17))
+		d, err2 := p.Duration("every", 0)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("param \"every\" must be positive")
+		}
+		return fxp.Every(fix.FromFloat(v), d, env.SampleRate), nil
+	})
+}
+
+// channels returns the "n" param, the number of channels, which defaults to 1.
+func channels(p Params) (int, error) {
+	n, err := p.Int("n", 1)
+	if err == nil && n < 1 {
+		return 0, fmt.Errorf("param \"n\" must be at least 1, got %d", n)
+	}
+	return n, err
+}
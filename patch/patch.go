@@ -0,0 +1,173 @@
+// package patch describes patches declaratively, so that they can be loaded,
+// saved and shared without recompiling.
+//
+// A Patch is a set of named nodes, each of which is a Ticker built by a
+// constructor from the registry, and the connections between them. Patches
+// are stored as JSON:
+//
+//	{
+//	  "outputs": 1,
+//	  "nodes": [
+//	    {"name": "note", "type": "Note", "params": {"note": 48}},
+//	    {"name": "osc", "type": "osc.Sine"}
+//	  ],
+//	  "connections": [
+//	    {"from": "note", "to": "osc"},
+//	    {"from": "osc:0", "to": "out:0"}
+//	  ]
+//	}
+//
+// Connection endpoints are a node name and an optional channel, which
+// defaults to 0. The names "in" and "out" refer to the patch's own inputs and
+// outputs. Packages that provide Tickers register constructors for them in
+// init functions, so the packages need to be imported for their Tickers to
+// be available.
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pfcm/fxp"
+)
+
+// Patch describes a graph of Tickers.
+type Patch struct {
+	Inputs  int `json:"inputs,omitempty"`
+	Outputs int `json:"outputs,omitempty"`
+	// FeedbackDelay overrides the delay in samples added to feedback
+	// connections if it is non-zero.
+	FeedbackDelay int          `json:"feedback_delay,omitempty"`
+	Nodes         []Node       `json:"nodes"`
+	Connections   []Connection `json:"connections"`
+}
+
+// Node is a single Ticker in a Patch.
+type Node struct {
+	// Name identifies the node in connections, it must be unique within
+	// the patch and can't be "in" or "out".
+	Name string `json:"name"`
+	// Type is the name the constructor was registered with.
+	Type   string `json:"type"`
+	Params Params `json:"params,omitempty"`
+}
+
+// Connection connects an output channel of one node to an input channel of
+// another. Each end is written "name:channel" or just "name" for channel 0.
+type Connection struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+const (
+	inputs  = "in"
+	outputs = "out"
+)
+
+// Parse reads a Patch from JSON.
+func Parse(r io.Reader) (*Patch, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var p Patch
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parsing patch: %w", err)
+	}
+	return &p, nil
+}
+
+// Write writes p as JSON.
+func (p *Patch) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// Load reads a patch from a file and builds it.
+func Load(path string, env Env) (*fxp.Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	g, err := p.Build(env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Build constructs the Tickers in the patch, connects them up and validates
+// the result.
+func (p *Patch) Build(env Env) (*fxp.Graph, error) {
+	if p.Inputs < 0 || p.Outputs < 0 {
+		return nil, fmt.Errorf("negative channel count (%d inputs, %d outputs)", p.Inputs, p.Outputs)
+	}
+	g := fxp.NewGraph(p.Inputs, p.Outputs)
+	if p.FeedbackDelay != 0 {
+		g.SetFeedbackDelay(p.FeedbackDelay)
+	}
+	nodes := map[string]fxp.Node{
+		inputs:  fxp.GraphInputs,
+		outputs: fxp.GraphOutputs,
+	}
+	for _, n := range p.Nodes {
+		if _, ok := nodes[n.Name]; ok || n.Name == "" {
+			return nil, fmt.Errorf("node %q: name is empty, reserved or already used", n.Name)
+		}
+		c, ok := lookup(n.Type)
+		if !ok {
+			return nil, fmt.Errorf("node %q: unknown type %q", n.Name, n.Type)
+		}
+		t, err := c(env, n.Params)
+		if err == nil {
+			// Make sure it's safe to add to the graph.
+			err = fxp.Validate(t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %s: %w", n.Name, n.Type, err)
+		}
+		nodes[n.Name] = g.Add(t)
+	}
+	for _, c := range p.Connections {
+		from, out, err := endpoint(nodes, c.From)
+		if err != nil {
+			return nil, err
+		}
+		to, in, err := endpoint(nodes, c.To)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.Connect(from, out, to, in); err != nil {
+			return nil, fmt.Errorf("connecting %s to %s: %w", c.From, c.To, err)
+		}
+	}
+	if err := fxp.Validate(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// endpoint parses one end of a connection.
+func endpoint(nodes map[string]fxp.Node, s string) (fxp.Node, int, error) {
+	name, ch, found := strings.Cut(s, ":")
+	n, ok := nodes[name]
+	if !ok {
+		return 0, 0, fmt.Errorf("connection %q: no node %q", s, name)
+	}
+	if !found {
+		return n, 0, nil
+	}
+	c, err := strconv.Atoi(ch)
+	if err != nil {
+		return 0, 0, fmt.Errorf("connection %q: bad channel: %w", s, err)
+	}
+	return n, c, nil
+}
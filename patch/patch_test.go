@@ -0,0 +1,193 @@
+package patch_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pfcm/fxp"
+	_ "github.com/pfcm/fxp/delay"
+	_ "github.com/pfcm/fxp/env"
+	"github.com/pfcm/fxp/fix"
+	_ "github.com/pfcm/fxp/osc"
+	"github.com/pfcm/fxp/patch"
+)
+
+var env = patch.Env{SampleRate: 44100}
+
+func TestLoad(t *testing.T) {
+	g, err := patch.Load("../cmd/play/delay.json", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Inputs() != 0 || g.Outputs() != 1 {
+		t.Errorf("got %d inputs, %d outputs, want: 0, 1", g.Inputs(), g.Outputs())
+	}
+	if g.FeedbackLatency() == 0 {
+		t.Error("no feedback latency")
+	}
+	out := [][]fix.S17{make([]fix.S17, 512)}
+	g.Tick(nil, out)
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, err := os.Open("../cmd/play/delay.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := patch.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := patch.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("round trip:\ngot:  %+v\nwant: %+v", got, p)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	p := &patch.Patch{
+		Inputs:  1,
+		Outputs: 2,
+		Nodes: []patch.Node{
+			{Name: "split", Type: "Mult", Params: patch.Params{"n": 2.0}},
+			{Name: "half", Type: "Scale", Params: patch.Params{"mul": 0.5}},
+		},
+		Connections: []patch.Connection{
+			{From: "in", To: "split"},
+			{From: "split:0", To: "out:0"},
+			{From: "split:1", To: "half"},
+			{From: "half", To: "out:1"},
+		},
+	}
+	g, err := p.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := [][]fix.S17{{0, 10, 64, -20}}
+	out := [][]fix.S17{make([]fix.S17, 4), make([]fix.S17, 4)}
+	g.Tick(in, out)
+	for i, s := range in[0] {
+		if out[0][i] != s {
+			t.Errorf("out[0][%d] = %v, want: %v", i, out[0][i], s)
+		}
+		if want := s.SMul(64); out[1][i] != want {
+			t.Errorf("out[1][%d] = %v, want: %v", i, out[1][i], want)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		json string
+		want string
+	}{{
+		name: "unknown type",
+		json: `{"nodes": [{"name": "a", "type": "Nope"}]}`,
+		want: `unknown type "Nope"`,
+	}, {
+		name: "duplicate name",
+		json: `{"nodes": [{"name": "a", "type": "Amp"}, {"name": "a", "type": "Amp"}]}`,
+		want: `node "a"`,
+	}, {
+		name: "reserved name",
+		json: `{"nodes": [{"name": "out", "type": "Amp"}]}`,
+		want: `node "out"`,
+	}, {
+		name: "bad param",
+		json: `{"nodes": [{"name": "a", "type": "Mult", "params": {"n": "two"}}]}`,
+		want: `param "n"`,
+	}, {
+		name: "constructor error",
+		json: `{"nodes": [{"name": "d", "type": "delay.Delay", "params": {"time": "0s"}}]}`,
+		want: `node "d": delay.Delay`,
+	}, {
+		name: "missing node",
+		json: `{"outputs": 1, "connections": [{"from": "a", "to": "out"}]}`,
+		want: `no node "a"`,
+	}, {
+		name: "bad channel",
+		json: `{"outputs": 1, "nodes": [{"name": "a", "type": "Amp"}], "connections": [{"from": "a:1", "to": "out"}]}`,
+		want: "has 1 outputs",
+	}, {
+		name: "negative noop",
+		json: `{"nodes": [{"name": "a", "type": "Noop", "params": {"n": -1}}]}`,
+		want: `param "n" must be at least 1, got -1`,
+	}, {
+		name: "negative sum",
+		json: `{"nodes": [{"name": "a", "type": "Sum", "params": {"n": -1}}]}`,
+		want: `node "a": Sum: param "n" must be at least 1, got -1`,
+	}, {
+		name: "empty sum",
+		json: `{"nodes": [{"name": "a", "type": "Sum", "params": {"n": 0}}]}`,
+		want: `param "n" must be at least 1, got 0`,
+	}, {
+		name: "negative mult",
+		json: `{"nodes": [{"name": "a", "type": "Mult", "params": {"n": -2}}]}`,
+		want: `param "n" must be at least 1, got -2`,
+	}, {
+		name: "bad quantization",
+		json: `{"nodes": [{"name": "a", "type": "Requantize", "params": {"quantization": "smooth"}}]}`,
+		want: `unknown quantization "smooth"`,
+	}, {
+		name: "negative requantize",
+		json: `{"nodes": [{"name": "a", "type": "Requantize", "params": {"n": -1, "bits": 4}}]}`,
+		want: `param "n" must be at least 1, got -1`,
+	}, {
+		name: "too many bits",
+		json: `{"nodes": [{"name": "a", "type": "Requantize", "params": {"bits": 12}}]}`,
+		want: "12 bits",
+	}, {
+		name: "bad interpolator",
+		json: `{"nodes": [{"name": "a", "type": "osc.Sine", "params": {"interp": "cubic"}}]}`,
+		want: `unknown interpolator "cubic"`,
+	}, {
+		name: "allpass wavetable",
+		json: `{"nodes": [{"name": "a", "type": "osc.Sine", "params": {"interp": "allpass"}}]}`,
+		want: "can't use allpass interpolation",
+	}, {
+		name: "bad fractional delay",
+		json: `{"nodes": [{"name": "a", "type": "delay.Fractional", "params": {"time": "1s", "interp": "sinc3"}}]}`,
+		want: "bad number of sinc taps 3",
+	}} {
+		p, err := patch.Parse(strings.NewReader(c.json))
+		if err != nil {
+			t.Errorf("%s: Parse: %v", c.name, err)
+			continue
+		}
+		_, err = p.Build(env)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: Build: got error %v, want one containing %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	patch.Register("test.Noop", func(patch.Env, patch.Params) (fxp.Ticker, error) {
+		return fxp.Noop{N: 1}, nil
+	})
+	found := false
+	for _, typ := range patch.Types() {
+		found = found || typ == "test.Noop"
+	}
+	if !found {
+		t.Errorf("test.Noop not in %v", patch.Types())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering twice didn't panic")
+		}
+	}()
+	patch.Register("test.Noop", nil)
+}
@@ -0,0 +1,135 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pfcm/fxp"
+)
+
+// Env describes where a patch is going to run.
+type Env struct {
+	SampleRate float32
+}
+
+// Constructor builds a Ticker from a node's parameters.
+type Constructor func(env Env, p Params) (fxp.Ticker, error)
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Constructor)
+)
+
+// Register makes a Constructor available to patches under the given type
+// name. Names are conventionally prefixed with the package, like "osc.Sine".
+// It panics if the name is already registered.
+func Register(typ string, c Constructor) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Errorf("patch: type %q registered twice", typ))
+	}
+	registry[typ] = c
+}
+
+// Types returns the names of all the registered types, sorted.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var out []string
+	for t := range registry {
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func lookup(typ string) (Constructor, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := registry[typ]
+	return c, ok
+}
+
+// Params holds a node's parameters, as decoded from JSON.
+type Params map[string]any
+
+// Float returns the named parameter as a float, or def if it isn't set.
+func (p Params) Float(name string, def float64) (float64, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("param %q: want a number, got %T", name, v)
+	}
+	return f, nil
+}
+
+// Int returns the named parameter as an int, or def if it isn't set.
+func (p Params) Int(name string, def int) (int, error) {
+	f, err := p.Float(name, float64(def))
+	if err != nil {
+		return 0, err
+	}
+	if f != float64(int(f)) {
+		return 0, fmt.Errorf("param %q: want an integer, got %v", name, f)
+	}
+	return int(f), nil
+}
+
+// String returns the named parameter as a string, or def if it isn't set.
+func (p Params) String(name, def string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("param %q: want a string, got %T", name, v)
+	}
+	return s, nil
+}
+
+// Duration returns the named parameter, a string like "200ms", as a
+// time.Duration, or def if it isn't set.
+func (p Params) Duration(name string, def time.Duration) (time.Duration, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("param %q: want a duration string, got %T", name, v)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("param %q: %w", name, err)
+	}
+	return d, nil
+}
+
+// Floats returns the named parameter as a list of floats, or nil if it isn't
+// set.
+func (p Params) Floats(name string) ([]float64, error) {
+	v, ok := p[name]
+	if !ok {
+		return nil, nil
+	}
+	l, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("param %q: want a list of numbers, got %T", name, v)
+	}
+	out := make([]float64, len(l))
+	for i, e := range l {
+		f, ok := e.(float64)
+		if !ok {
+			return nil, fmt.Errorf("param %q[%d]: want a number, got %T", name, i, e)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
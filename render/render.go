@@ -0,0 +1,146 @@
+// package render runs Tickers offline, as fast as possible, rather than in
+// time with a sound device.
+package render
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+const (
+	// DefaultBlockSize is the block size used if Options.BlockSize is 0.
+	DefaultBlockSize = 512
+	// DefaultSampleRate is the sample rate used if Options.SampleRate is 0.
+	DefaultSampleRate = 44100
+)
+
+// Options configures a render.
+type Options struct {
+	// BlockSize is the number of samples per channel to process at once.
+	BlockSize int
+	// SampleRate is used to convert durations to samples.
+	SampleRate float32
+	// Input generates the inputs to the Ticker being rendered, so it
+	// should have no inputs and as many outputs as that Ticker has inputs.
+	// If it's nil the inputs are silent.
+	Input fxp.Ticker
+}
+
+func (o Options) blockSize() int {
+	if o.BlockSize <= 0 {
+		return DefaultBlockSize
+	}
+	return o.BlockSize
+}
+
+func (o Options) sampleRate() float32 {
+	if o.SampleRate <= 0 {
+		return DefaultSampleRate
+	}
+	return o.SampleRate
+}
+
+// Samples returns the number of samples in d at the configured sample rate.
+func (o Options) Samples(d time.Duration) int {
+	return int(d.Seconds() * float64(o.sampleRate()))
+}
+
+// Sink receives rendered audio, eg. an encoder writing to a file.
+type Sink interface {
+	// Write receives the next block of audio, one slice per channel. The
+	// slices are reused, so they shouldn't be kept after Write returns.
+	Write(block [][]fix.S17) error
+}
+
+// Render runs t for n samples, passing each block it outputs to sink.
+func Render(sink Sink, t fxp.Ticker, n int, opts Options) error {
+	if n < 0 {
+		return fmt.Errorf("can't render %d samples", n)
+	}
+	block := opts.blockSize()
+	if err := fxp.Validate(t); err != nil {
+		return err
+	}
+	fxp.Prepare(t, block)
+	if opts.Input != nil {
+		if opts.Input.Inputs() != 0 || opts.Input.Outputs() != t.Inputs() {
+			return fmt.Errorf("input %v has %d inputs and %d outputs, want: 0 and %d",
+				opts.Input, opts.Input.Inputs(), opts.Input.Outputs(), t.Inputs())
+		}
+		if err := fxp.Validate(opts.Input); err != nil {
+			return err
+		}
+		fxp.Prepare(opts.Input, block)
+	}
+	inBufs, outBufs := makeBufs(t.Inputs(), block), makeBufs(t.Outputs(), block)
+	ins, outs := make([][]fix.S17, len(inBufs)), make([][]fix.S17, len(outBufs))
+	for done := 0; done < n; done += block {
+		m := min(block, n-done)
+		for i := range ins {
+			ins[i] = inBufs[i][:m]
+			clear(ins[i])
+		}
+		for i := range outs {
+			outs[i] = outBufs[i][:m]
+			clear(outs[i])
+		}
+		if opts.Input != nil {
+			opts.Input.Tick(nil, ins)
+		}
+		t.Tick(ins, outs)
+		if err := sink.Write(outs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// For runs t for the duration d, passing each block it outputs to sink.
+func For(sink Sink, t fxp.Ticker, d time.Duration, opts Options) error {
+	return Render(sink, t, opts.Samples(d), opts)
+}
+
+// Buffer is a Sink that keeps everything in memory.
+type Buffer struct {
+	// Channels holds all the audio written so far.
+	Channels [][]fix.S17
+}
+
+func (b *Buffer) Write(block [][]fix.S17) error {
+	if b.Channels == nil {
+		b.Channels = make([][]fix.S17, len(block))
+	}
+	if len(block) != len(b.Channels) {
+		return fmt.Errorf("got %d channels, want: %d", len(block), len(b.Channels))
+	}
+	for i, c := range block {
+		b.Channels[i] = append(b.Channels[i], c...)
+	}
+	return nil
+}
+
+// ToBuffer runs t for n samples and returns everything it output.
+func ToBuffer(t fxp.Ticker, n int, opts Options) ([][]fix.S17, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("can't render %d samples", n)
+	}
+	b := Buffer{Channels: makeBufs(t.Outputs(), 0)}
+	for i := range b.Channels {
+		b.Channels[i] = make([]fix.S17, 0, n)
+	}
+	if err := Render(&b, t, n, opts); err != nil {
+		return nil, err
+	}
+	return b.Channels, nil
+}
+
+func makeBufs(chans, n int) [][]fix.S17 {
+	out := make([][]fix.S17, chans)
+	for i := range out {
+		out[i] = make([]fix.S17, n)
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+)
+
+// counter outputs an increasing ramp, so we can check nothing is dropped or
+// repeated.
+type counter struct{ n fix.S17 }
+
+func (*counter) Inputs() int    { return 0 }
+func (*counter) Outputs() int   { return 1 }
+func (*counter) String() string { return "counter" }
+
+func (c *counter) Tick(_, out [][]fix.S17) {
+	for i := range out[0] {
+		out[0][i] = c.n
+		c.n++
+	}
+}
+
+func TestToBuffer(t *testing.T) {
+	for _, block := range []int{0, 1, 7, 100, 1000} {
+		opts := Options{BlockSize: block, Input: &counter{}}
+		got, err := ToBuffer(fxp.Serially(fxp.Mult{N: 2}, fxp.Concurrently(fxp.Noop{N: 1}, fxp.Scale{Mul: 64})), 300, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || len(got[0]) != 300 || len(got[1]) != 300 {
+			t.Fatalf("block %d: got %d channels of %d samples, want 2 of 300", block, len(got), len(got[0]))
+		}
+		for i := range got[0] {
+			want := fix.S17(i)
+			if got[0][i] != want || got[1][i] != want.SMul(64) {
+				t.Fatalf("block %d: sample %d = %v, %v, want: %v, %v", block, i, got[0][i], got[1][i], want, want.SMul(64))
+			}
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	var b Buffer
+	opts := Options{SampleRate: 8000}
+	if err := For(&b, fxp.Const{Val: 1}, 250*time.Millisecond, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Channels) != 1 || len(b.Channels[0]) != 2000 {
+		t.Errorf("got %d channels, want 1 of 2000 samples", len(b.Channels))
+	}
+}
+
+type failSink struct{ n int }
+
+func (f *failSink) Write([][]fix.S17) error {
+	f.n--
+	if f.n < 0 {
+		return errors.New("full")
+	}
+	return nil
+}
+
+func TestRenderErrors(t *testing.T) {
+	if err := Render(&failSink{n: 2}, fxp.Const{}, 10000, Options{}); err == nil {
+		t.Error("Render didn't return the sink's error")
+	}
+	if err := Render(&Buffer{}, fxp.Mixer{}, 10, Options{}); err == nil {
+		t.Error("Render accepted an invalid ticker")
+	}
+	if err := Render(&Buffer{}, fxp.Amp{}, 10, Options{Input: fxp.Const{}}); err == nil {
+		t.Error("Render accepted an input with the wrong number of channels")
+	}
+	if err := Render(&Buffer{}, fxp.Const{}, -1, Options{}); err == nil {
+		t.Error("Render accepted a negative length")
+	}
+	if _, err := ToBuffer(fxp.Const{}, -1, Options{}); err == nil {
+		t.Error("ToBuffer accepted a negative length")
+	}
+	if err := For(&Buffer{}, fxp.Const{}, -time.Second, Options{}); err == nil {
+		t.Error("For accepted a negative duration")
+	}
+}
@@ -0,0 +1,55 @@
+package fxp
+
+import (
+	"fmt"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// Requantize reduces its inputs to fewer bits of resolution, using a
+// fix.Quantizer for each channel. With fix.Truncate it's a plain bit crusher,
+// the other quantizations trade the distortion for noise.
+type Requantize struct {
+	bits int
+	qs   []fix.Quantizer
+}
+
+var (
+	_ Ticker    = &Requantize{}
+	_ Validator = &Requantize{}
+)
+
+// NewRequantize returns a Requantize with n channels keeping the given number
+// of bits, from 1 to 8. It panics if n is negative.
+func NewRequantize(n, bits int, q fix.Quantization) *Requantize {
+	qs := make([]fix.Quantizer, n)
+	for i := range qs {
+		qs[i] = q.New()
+	}
+	return &Requantize{bits: bits, qs: qs}
+}
+
+func (r *Requantize) Inputs() int    { return len(r.qs) }
+func (r *Requantize) Outputs() int   { return len(r.qs) }
+func (r *Requantize) String() string { return fmt.Sprintf("Requantize(%d bits)", r.bits) }
+
+func (r *Requantize) Validate() error {
+	if r.bits < 1 || r.bits > 8 {
+		return fmt.Errorf("can't requantize to %d bits", r.bits)
+	}
+	return nil
+}
+
+func (r *Requantize) Tick(input, output [][]fix.S17) {
+	// Quantize at a smaller scale, so that the quantizer's steps are the
+	// size of the ones we want, then scale back up.
+	shift := 8 - r.bits
+	scale := 1 / float32(int(1)<<shift)
+	top := int(fix.MaxS17) &^ (1<<shift - 1)
+	for c, q := range r.qs {
+		for i, s := range input[c] {
+			v := int(q.Quantize(fix.Float[float32](s)*scale)) << shift
+			output[c][i] = fix.S17(min(v, top))
+		}
+	}
+}
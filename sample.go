@@ -0,0 +1,181 @@
+package fxp
+
+import (
+	"fmt"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// Sample is the set of sample types that Tickers can process.
+type Sample interface {
+	fix.S17 | fix.S115 | float32
+}
+
+// generic converts a slice of Tickers into a slice of TickerOf[fix.S17].
+func generic(ts []Ticker) []TickerOf[fix.S17] {
+	out := make([]TickerOf[fix.S17], len(ts))
+	for i, t := range ts {
+		out[i] = t
+	}
+	return out
+}
+
+// mix adds src into dst, saturating if the sample type can.
+func mix[T Sample](dst, src []T) {
+	switch d := any(dst).(type) {
+	case []fix.S17:
+		for i, s := range any(src).([]fix.S17) {
+			d[i] = d[i].SAdd(s)
+		}
+	case []fix.S115:
+		for i, s := range any(src).([]fix.S115) {
+			d[i] = d[i].SAdd(s)
+		}
+	case []float32:
+		for i, s := range any(src).([]float32) {
+			d[i] += s
+		}
+	}
+}
+
+// Convert converts samples from one type to another, clamping if the
+// destination can't represent them. Converting to a narrower fixed point
+// type truncates.
+func Convert[To, From Sample](dst []To, src []From) {
+	// Fixed point to fixed point doesn't need to go via float.
+	switch d := any(dst).(type) {
+	case []fix.S17:
+		if s, ok := any(src).([]fix.S115); ok {
+			for i, x := range s {
+				d[i] = x.S17()
+			}
+			return
+		}
+	case []fix.S115:
+		if s, ok := any(src).([]fix.S17); ok {
+			for i, x := range s {
+				d[i] = x.S115()
+			}
+			return
+		}
+	}
+	switch s := any(src).(type) {
+	case []float32:
+		fromFloat(dst, s)
+	case []fix.S17:
+		for i, x := range s {
+			dst[i] = sampleFromFloat[To](fix.Float[float32](x))
+		}
+	case []fix.S115:
+		for i, x := range s {
+			dst[i] = sampleFromFloat[To](fix.S115ToFloat[float32](x))
+		}
+	}
+}
+
+func fromFloat[T Sample](dst []T, src []float32) {
+	switch d := any(dst).(type) {
+	case []float32:
+		copy(d, src)
+	case []fix.S17:
+		for i, f := range src {
+			d[i] = fix.FromFloat(f)
+		}
+	case []fix.S115:
+		for i, f := range src {
+			d[i] = fix.S115FromFloat(f)
+		}
+	}
+}
+
+func sampleFromFloat[T Sample](f float32) T {
+	var t T
+	switch p := any(&t).(type) {
+	case *float32:
+		*p = f
+	case *fix.S17:
+		*p = fix.FromFloat(f)
+	case *fix.S115:
+		*p = fix.S115FromFloat(f)
+	}
+	return t
+}
+
+// Adapter runs a TickerOf[Inner] in a graph of Outer samples, converting its
+// inputs and outputs at the boundary.
+type Adapter[Outer, Inner Sample] struct {
+	t         TickerOf[Inner]
+	ins, outs [][]Inner
+	// subIns and subOuts hold pieces of blocks that are too long for ins
+	// and outs.
+	subIns, subOuts [][]Outer
+}
+
+// Adapt wraps t so that it can be used with Outer samples.
+func Adapt[Outer, Inner Sample](t TickerOf[Inner]) Adapter[Outer, Inner] {
+	a := Adapter[Outer, Inner]{
+		t:       t,
+		ins:     make([][]Inner, t.Inputs()),
+		outs:    make([][]Inner, t.Outputs()),
+		subIns:  make([][]Outer, t.Inputs()),
+		subOuts: make([][]Outer, t.Outputs()),
+	}
+	a.alloc(DefaultMaxBlock)
+	return a
+}
+
+var _ Ticker = Adapter[fix.S17, fix.S115]{}
+var _ Preparer = Adapter[fix.S17, fix.S115]{}
+var _ Validator = Adapter[fix.S17, fix.S115]{}
+
+func (a Adapter[Outer, Inner]) alloc(maxBlock int) {
+	for i := range a.ins {
+		a.ins[i] = make([]Inner, maxBlock)
+	}
+	for i := range a.outs {
+		a.outs[i] = make([]Inner, maxBlock)
+	}
+}
+
+func (a Adapter[Outer, Inner]) maxBlock() int {
+	switch {
+	case len(a.ins) != 0:
+		return cap(a.ins[0])
+	case len(a.outs) != 0:
+		return cap(a.outs[0])
+	}
+	return 0
+}
+
+func (a Adapter[Outer, Inner]) Inputs() int    { return a.t.Inputs() }
+func (a Adapter[Outer, Inner]) Outputs() int   { return a.t.Outputs() }
+func (a Adapter[Outer, Inner]) String() string { return fmt.Sprintf("Adapt(%v)", a.t) }
+
+func (a Adapter[Outer, Inner]) Validate() error {
+	return withPath(Validate(a.t), "Adapt")
+}
+
+func (a Adapter[Outer, Inner]) Prepare(maxBlock int) {
+	a.alloc(maxBlock)
+	Prepare(a.t, maxBlock)
+}
+
+func (a Adapter[Outer, Inner]) Tick(inputs, outputs [][]Outer) {
+	split(a.maxBlock(), inputs, outputs, a.subIns, a.subOuts, a.tick)
+}
+
+func (a Adapter[Outer, Inner]) tick(inputs, outputs [][]Outer) {
+	n := blockLen(inputs, outputs)
+	for i := range inputs {
+		a.ins[i] = a.ins[i][:n]
+		Convert(a.ins[i], inputs[i])
+	}
+	for i := range a.outs {
+		a.outs[i] = a.outs[i][:n]
+		clear(a.outs[i])
+	}
+	a.t.Tick(a.ins, a.outs)
+	for i := range outputs {
+		Convert(outputs[i], a.outs[i])
+	}
+}
@@ -0,0 +1,91 @@
+package fxp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// half halves its input, for any sample type.
+type half[T Sample] struct{}
+
+func (half[T]) Inputs() int    { return 1 }
+func (half[T]) Outputs() int   { return 1 }
+func (half[T]) String() string { return "half" }
+
+func (half[T]) Tick(in, out [][]T) {
+	for i, s := range in[0] {
+		out[0][i] = s / 2
+	}
+}
+
+func TestGraphOfS115(t *testing.T) {
+	g := NewGraphOf[fix.S115](1, 1)
+	a := g.Add(half[fix.S115]{})
+	b := g.Add(SeriallyOf[fix.S115](NoopOf[fix.S115]{N: 1}, half[fix.S115]{}))
+	for _, e := range [][2]Node{{GraphInputs, a}, {GraphInputs, b}, {a, GraphOutputs}, {b, GraphOutputs}} {
+		if err := g.Wire(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := [][]fix.S115{{0x1000, 0x7000, -0x8000}}
+	out := [][]fix.S115{make([]fix.S115, 3)}
+	g.Tick(in, out)
+	for i, want := range []fix.S115{0x1000, 0x7000, -0x8000} {
+		if out[0][i] != want {
+			t.Errorf("out[0][%d] = %v, want: %v", i, out[0][i], want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	s17 := []fix.S17{fix.MinS17, -1, 0, 1, 64, fix.MaxS17}
+	f := make([]float32, len(s17))
+	Convert(f, s17)
+	s115 := make([]fix.S115, len(s17))
+	Convert(s115, f)
+	back := make([]fix.S17, len(s17))
+	Convert(back, s115)
+	for i := range s17 {
+		if want := fix.Float[float32](s17[i]); f[i] != want {
+			t.Errorf("float %d: got %v, want: %v", i, f[i], want)
+		}
+		if want := s17[i].S115(); s115[i] != want {
+			t.Errorf("S115 %d: got %v, want: %v", i, s115[i], want)
+		}
+		if back[i] != s17[i] {
+			t.Errorf("round trip %d: got %v, want: %v", i, back[i], s17[i])
+		}
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	for _, c := range []struct {
+		inner Ticker
+		want  func(fix.S17) fix.S17
+	}{{
+		// S115 keeps the fraction, then narrowing to S17 rounds
+		// towards -inf.
+		inner: Adapt[fix.S17](half[fix.S115]{}),
+		want:  func(s fix.S17) fix.S17 { return s >> 1 },
+	}, {
+		// float32 also keeps the fraction, but fix.FromFloat rounds
+		// towards zero.
+		inner: Adapt[fix.S17](half[float32]{}),
+		want:  func(s fix.S17) fix.S17 { return s / 2 },
+	}} {
+		t.Run(fmt.Sprintf("%T", c.inner), func(t *testing.T) {
+			ch := Serially(Noop{N: 1}, c.inner)
+			Prepare(ch, 4)
+			in := [][]fix.S17{{0, 1, 2, 3, 64, 127, -128, -3, -1, 5}}
+			out := makeBufs(1, len(in[0]))
+			ch.Tick(in, out)
+			for i, got := range out[0] {
+				if want := c.want(in[0][i]); got != want {
+					t.Errorf("out[0][%d] = %v, want: %v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package spectral
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/patch"
+)
+
+func init() {
+	register("Freeze", func(patch.Params) (Processor, error) {
+		return NewFreeze(), nil
+	})
+	register("Gate", func(p patch.Params) (Processor, error) {
+		threshold, err := p.Float("threshold", 0.01)
+		if err != nil {
+			return nil, err
+		}
+		floor, err := p.Float("floor", 0)
+		return &Gate{Threshold: fix.FromFloat(threshold), Floor: fix.FromFloat(floor)}, err
+	})
+	register("Shift", func(p patch.Params) (Processor, error) {
+		b, err := p.Int("bins", 0)
+		return &Shift{Bins: b}, err
+	})
+	register("Pitch", func(p patch.Params) (Processor, error) {
+		ratio, err := p.Float("ratio", 1)
+		if err != nil {
+			return nil, err
+		}
+		if ratio <= 0 {
+			return nil, fmt.Errorf("pitch ratio %v isn't positive", ratio)
+		}
+		return &Pitch{Ratio: fix.U1616FromFloat(ratio)}, nil
+	})
+	register("Blur", func(p patch.Params) (Processor, error) {
+		b, err := p.Int("bins", 2)
+		if err != nil {
+			return nil, err
+		}
+		if b < 0 {
+			return nil, fmt.Errorf("negative blur %d", b)
+		}
+		time, err := p.Float("time", 0)
+		return &Blur{Bins: b, Time: fix.FromFloat(time)}, err
+	})
+	register("BitStarve", func(p patch.Params) (Processor, error) {
+		b, err := p.Int("bits", 3)
+		if err != nil {
+			return nil, err
+		}
+		if b < 1 || b > 8 {
+			return nil, fmt.Errorf("can't starve to %d bits, want 1 to 8", b)
+		}
+		return &BitStarve{Bits: b}, nil
+	})
+}
+
+// register registers an Effect running a Processor as spectral.<name>, with
+// the params for the frames as well as the Processor's own.
+func register(name string, proc func(patch.Params) (Processor, error)) {
+	patch.Register("spectral."+name, func(_ patch.Env, p patch.Params) (fxp.Ticker, error) {
+		size, err := p.Int("size", 512)
+		if err != nil {
+			return nil, err
+		}
+		hop, err := p.Int("hop", size/4)
+		if err != nil {
+			return nil, err
+		}
+		wname, err := p.String("window", "hann")
+		if err != nil {
+			return nil, err
+		}
+		w, err := ParseWindow(wname)
+		if err != nil {
+			return nil, err
+		}
+		pr, err := proc(p)
+		if err != nil {
+			return nil, err
+		}
+		return New(size, hop, w, pr)
+	})
+}
+
+var (
+	_ Controlled = &Freeze{}
+	_ Processor  = &Gate{}
+	_ Processor  = &Shift{}
+	_ Processor  = &Pitch{}
+	_ Processor  = &Blur{}
+	_ Processor  = &BitStarve{}
+)
+
+// neg negates a coefficient, saturating.
+func neg(c fix.S17) fix.S17 {
+	return -max(c, -fix.MaxS17)
+}
+
+// Freeze holds on to the spectrum while its control input is above zero, so
+// a moment of sound carries on. The signs of the held coefficients are
+// shuffled every frame, otherwise repeating the same frame would buzz at the
+// hop rate.
+type Freeze struct {
+	held    []fix.S17
+	heldExp int
+	r       *rand.Rand
+}
+
+// NewFreeze returns a Freeze that isn't holding anything yet.
+func NewFreeze() *Freeze {
+	return &Freeze{r: rand.New(rand.NewPCG(1, 2))}
+}
+
+func (*Freeze) Controls() int  { return 1 }
+func (*Freeze) String() string { return "Freeze" }
+
+func (f *Freeze) Process(fr *Frame) {
+	if fr.Controls[0] <= 0 || f.held == nil {
+		f.held = append(f.held[:0], fr.Coeffs...)
+		f.heldExp = fr.Exp
+		return
+	}
+	signs := f.r.Uint64()
+	for k, c := range f.held {
+		if k%64 == 0 && k > 0 {
+			signs = f.r.Uint64()
+		}
+		if signs&(1<<(k%64)) != 0 {
+			c = neg(c)
+		}
+		fr.Coeffs[k] = c
+	}
+	fr.Exp = f.heldExp
+}
+
+// Gate turns down the quiet parts of the spectrum. A low Threshold takes out
+// a steady hiss, a high one leaves just the loudest partials. Threshold is the
+// amplitude of a sine wave on a single coefficient, and the coefficients
+// under it are multiplied by Floor.
+type Gate struct {
+	Threshold, Floor fix.S17
+}
+
+func (g *Gate) String() string { return fmt.Sprintf("Gate(%v, %v)", g.Threshold, g.Floor) }
+
+func (g *Gate) Process(fr *Frame) {
+	// A sine of amplitude a makes a coefficient of about a * n / 2, so
+	// compare 2 * |c| * 2^Exp with Threshold * n, both as S17s.
+	n := len(fr.Coeffs)
+	rhs := int64(g.Threshold) * int64(n)
+	shift := fr.Exp + 1
+	for k, c := range fr.Coeffs {
+		lhs := int64(max(c, -c, -fix.MaxS17))
+		r := rhs
+		if shift >= 0 {
+			lhs <<= min(shift, 32)
+		} else {
+			r <<= min(-shift, 32)
+		}
+		if lhs < r {
+			fr.Coeffs[k] = c.SMul(g.Floor)
+		}
+	}
+}
+
+// Shift moves every coefficient up by Bins, or down if it's negative. That
+// shifts every frequency by the same amount, so harmonic sounds turn
+// inharmonic and clangy. A bin is the sample rate / 2n for frames of n.
+type Shift struct {
+	Bins int
+}
+
+func (s *Shift) String() string { return fmt.Sprintf("Shift(%d)", s.Bins) }
+
+func (s *Shift) Process(fr *Frame) {
+	c := fr.Coeffs
+	b := min(max(s.Bins, -len(c)), len(c))
+	if b >= 0 {
+		copy(c[b:], c)
+		clear(c[:b])
+		return
+	}
+	copy(c, c[-b:])
+	clear(c[len(c)+b:])
+}
+
+// Pitch scales every frequency by Ratio, by moving coefficient k to k * Ratio.
+// That keeps harmonics in tune, but the coefficients are coarse so low notes
+// come out smeared and scaling up leaves gaps filled by repeats.
+type Pitch struct {
+	Ratio fix.U1616
+	buf   []fix.S17
+}
+
+func (p *Pitch) String() string { return fmt.Sprintf("Pitch(%v)", p.Ratio) }
+
+func (p *Pitch) Process(fr *Frame) {
+	if p.Ratio == 0 {
+		clear(fr.Coeffs)
+		return
+	}
+	p.buf = append(p.buf[:0], fr.Coeffs...)
+	for j := range fr.Coeffs {
+		// Pull from the nearest coefficient, so there are no holes.
+		k := (int64(j)<<17/int64(p.Ratio) + 1) >> 1
+		var c fix.S17
+		if k < int64(len(p.buf)) {
+			c = p.buf[k]
+		}
+		fr.Coeffs[j] = c
+	}
+}
+
+// Blur smears the spectrum over Bins neighbouring coefficients either side,
+// and over time by mixing in Time of the previous frame, which washes
+// transients out into a haze.
+type Blur struct {
+	Bins int
+	Time fix.S17
+	// wide holds the coefficients with blurBits more fractional bits,
+	// prev the last output the same way and prevExp its exponent.
+	wide, sums, prev []int64
+	prevExp          int
+}
+
+// blurBits is the extra precision Blur works with.
+const blurBits = 16
+
+func (b *Blur) String() string { return fmt.Sprintf("Blur(%d, %v)", b.Bins, b.Time) }
+
+func (b *Blur) Process(fr *Frame) {
+	n := len(fr.Coeffs)
+	if len(b.wide) != n {
+		b.wide, b.sums, b.prev = make([]int64, n), make([]int64, n+1), make([]int64, n)
+		b.prevExp = fr.Exp
+	}
+	// Line this frame and the last up on the bigger exponent, then mix
+	// them.
+	t := int64(max(b.Time, 0))
+	exp := max(fr.Exp, b.prevExp)
+	cs, ps := min(exp-fr.Exp, 63), min(exp-b.prevExp, 63)
+	for k, c := range fr.Coeffs {
+		cur := int64(c) << blurBits >> cs
+		b.wide[k] = (cur*(128-t) + b.prev[k]>>ps*t) >> 7
+	}
+	// A box filter from running sums, shrinking at the edges.
+	for k, v := range b.wide {
+		b.sums[k+1] = b.sums[k] + v
+	}
+	for k := range b.wide {
+		lo, hi := max(k-b.Bins, 0), min(k+b.Bins+1, n)
+		b.prev[k] = (b.sums[hi] - b.sums[lo]) / int64(hi-lo)
+	}
+	fr.Exp = normalize(fr.Coeffs, b.prev, exp)
+	b.prevExp = fr.Exp
+	// Keep the output at the exponent it's now at.
+	for k := range b.prev {
+		b.prev[k] = int64(fr.Coeffs[k]) << blurBits
+	}
+}
+
+// normalize narrows vs, which have blurBits more fractional bits than
+// coefficients at exp, into dst with a new exponent that uses all the bits.
+func normalize(dst []fix.S17, vs []int64, exp int) int {
+	m := int64(0)
+	for _, v := range vs {
+		m = max(m, v, -v)
+	}
+	if m == 0 {
+		clear(dst)
+		return exp
+	}
+	shift := bits.Len64(uint64(m)) - 7
+	if shift > 0 && (m+1<<(shift-1))>>shift > int64(fix.MaxS17) {
+		shift++
+	}
+	for i, v := range vs {
+		if shift > 0 {
+			v = (v + 1<<(shift-1)) >> shift
+		} else {
+			v <<= -shift
+		}
+		dst[i] = fix.S17(min(max(v, int64(fix.MinS17)), int64(fix.MaxS17)))
+	}
+	return exp + shift - blurBits
+}
+
+// BitStarve rounds the size of every coefficient to Bits bits and keeps its
+// sign, from gritty at 4 or so down to a handful of sine waves at 1, where
+// only the coefficients above half scale survive. The frames share an
+// exponent, so the bits are spent relative to the loudest coefficient.
+type BitStarve struct {
+	Bits int
+}
+
+func (b *BitStarve) String() string { return fmt.Sprintf("BitStarve(%d)", b.Bits) }
+
+func (b *BitStarve) Process(fr *Frame) {
+	if b.Bits >= 8 {
+		return
+	}
+	if b.Bits <= 0 {
+		clear(fr.Coeffs)
+		return
+	}
+	// Rounding the magnitude rather than the value keeps the levels
+	// symmetric about zero. The top level is full scale, which is one
+	// step above MaxS17.
+	shift := 8 - b.Bits
+	for k, c := range fr.Coeffs {
+		m := max(int(c), -int(c))
+		m = min((m+1<<(shift-1))>>shift<<shift, int(fix.MaxS17))
+		if c < 0 {
+			m = -m
+		}
+		fr.Coeffs[k] = fix.S17(m)
+	}
+}
@@ -0,0 +1,137 @@
+package spectral
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+	"github.com/pfcm/fxp/patch"
+)
+
+func TestProcessors(t *testing.T) {
+	for _, c := range []struct {
+		p       Processor
+		in      []fix.S17
+		exp     int
+		want    []fix.S17
+		wantExp int
+	}{
+		{&Shift{Bins: 2}, []fix.S17{1, 2, 3, 4, 5}, 3, []fix.S17{0, 0, 1, 2, 3}, 3},
+		{&Shift{Bins: -1}, []fix.S17{1, 2, 3, 4, 5}, 3, []fix.S17{2, 3, 4, 5, 0}, 3},
+		{&Shift{Bins: 10}, []fix.S17{1, 2, 3}, 3, []fix.S17{0, 0, 0}, 3},
+		{&Pitch{Ratio: 2 << 16}, []fix.S17{1, 2, 3, 4, 5, 6}, 0, []fix.S17{1, 2, 2, 3, 3, 4}, 0},
+		{&Pitch{Ratio: 1 << 15}, []fix.S17{1, 2, 3, 4, 5, 6}, 0, []fix.S17{1, 3, 5, 0, 0, 0}, 0},
+		{&BitStarve{Bits: 2}, []fix.S17{127, 100, 33, 31, -31, -33, -128}, 1, []fix.S17{127, 127, 64, 0, 0, -64, -127}, 1},
+		{&BitStarve{Bits: 2}, []fix.S17{96, -96, 95, -95, 32, -32}, 1, []fix.S17{127, -127, 64, -64, 64, -64}, 1},
+		{&BitStarve{Bits: 1}, []fix.S17{127, 64, 63, 0, -63, -64, -128}, 1, []fix.S17{127, 127, 0, 0, 0, -127, -127}, 1},
+		{&BitStarve{Bits: 4}, []fix.S17{127, 9, 7, -7, -9, -127}, 1, []fix.S17{127, 16, 0, 0, -16, -127}, 1},
+		{&BitStarve{Bits: 8}, []fix.S17{127, 1, -128}, 1, []fix.S17{127, 1, -128}, 1},
+		// At 2^-1 in frames of 4, the amplitude is 2 * |c| / 128 *
+		// 2^-1 / 4, so 16/128 lets through 64 and up.
+		{&Gate{Threshold: 16}, []fix.S17{127, 64, 63, -63}, -1, []fix.S17{127, 64, 0, 0}, -1},
+		{&Gate{Threshold: 16, Floor: 64}, []fix.S17{127, 64, 63, -63}, -1, []fix.S17{127, 64, 31, -32}, -1},
+		// An impulse spreads out, and gets as many bits as it can.
+		{&Blur{Bins: 1}, []fix.S17{0, 0, 120, 0, 0}, 2, []fix.S17{0, 80, 80, 80, 0}, 1},
+	} {
+		f := &Frame{Coeffs: slices.Clone(c.in), Exp: c.exp}
+		c.p.Process(f)
+		if !slices.Equal(f.Coeffs, c.want) || f.Exp != c.wantExp {
+			t.Errorf("%v: %v * 2^%d = %v * 2^%d, want: %v * 2^%d", c.p, c.in, c.exp, f.Coeffs, f.Exp, c.want, c.wantExp)
+		}
+	}
+}
+
+func TestBlurTime(t *testing.T) {
+	b := &Blur{Time: 64}
+	f := &Frame{Coeffs: []fix.S17{64, -64}, Exp: 0}
+	b.Process(f)
+	// Half of the first frame, with nothing before it.
+	if !slices.Equal(f.Coeffs, []fix.S17{64, -64}) || f.Exp != -1 {
+		t.Errorf("first frame = %v * 2^%d, want: [64 -64] * 2^-1", f.Coeffs, f.Exp)
+	}
+	// Silence fades out by halves.
+	for _, want := range []int{-2, -3} {
+		f := &Frame{Coeffs: []fix.S17{0, 0}, Exp: 5}
+		b.Process(f)
+		if !slices.Equal(f.Coeffs, []fix.S17{64, -64}) || f.Exp != want {
+			t.Errorf("silence = %v * 2^%d, want: [64 -64] * 2^%d", f.Coeffs, f.Exp, want)
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	e, err := New(64, 16, Hann, NewFreeze())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Inputs() != 2 {
+		t.Fatalf("Inputs() = %d, want: 2", e.Inputs())
+	}
+	// A sine, then silence while the control is held, then silence.
+	const n = 1000
+	in, ctl := sine(n, 40), make([]fix.S17, n)
+	clear(in[400:])
+	for i := 380; i < 700; i++ {
+		ctl[i] = 1
+	}
+	out := make([]fix.S17, n)
+	e.Tick([][]fix.S17{in, ctl}, [][]fix.S17{out})
+	loud := func(s []fix.S17) bool {
+		for _, x := range s {
+			if x > 20 || x < -20 {
+				return true
+			}
+		}
+		return false
+	}
+	if !loud(out[600:700]) {
+		t.Errorf("frozen output is quiet: %v", out[600:700])
+	}
+	if loud(out[800:]) {
+		t.Errorf("output after letting go is loud: %v", out[800:])
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	for _, c := range []struct {
+		typ, params string
+		want        string
+	}{
+		{"Freeze", `{}`, "spectral.Effect(512, 128, hann, Freeze)"},
+		{"Gate", `{"threshold": 0.5, "floor": 0.25}`, "Gate(0.5000000, 0.2500000)"},
+		{"Shift", `{"bins": -3, "size": 64, "hop": 32, "window": "sqrthann"}`, "spectral.Effect(64, 32, sqrthann, Shift(-3))"},
+		{"Pitch", `{"ratio": 1.5}`, "Pitch(1.5"},
+		{"Blur", `{"bins": 4, "time": 0.5}`, "Blur(4, 0.5000000)"},
+		{"BitStarve", `{"bits": 2}`, "BitStarve(2)"},
+	} {
+		json := `{"nodes": [{"name": "a", "type": "spectral.` + c.typ + `", "params": ` + c.params + `}]}`
+		p, err := patch.Parse(strings.NewReader(json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := p.Build(patch.Env{SampleRate: 44100})
+		if err != nil {
+			t.Errorf("%s: %v", c.typ, err)
+			continue
+		}
+		if got := g.String(); !strings.Contains(got, c.want) {
+			t.Errorf("%s: built %s, want it to contain %s", c.typ, got, c.want)
+		}
+	}
+	for _, json := range []string{
+		`{"nodes": [{"name": "a", "type": "spectral.BitStarve", "params": {"bits": 9}}]}`,
+		`{"nodes": [{"name": "a", "type": "spectral.Pitch", "params": {"ratio": 0}}]}`,
+		`{"nodes": [{"name": "a", "type": "spectral.Blur", "params": {"bins": -1}}]}`,
+		`{"nodes": [{"name": "a", "type": "spectral.Gate", "params": {"window": "blackman"}}]}`,
+		`{"nodes": [{"name": "a", "type": "spectral.Gate", "params": {"size": 100}}]}`,
+	} {
+		p, err := patch.Parse(strings.NewReader(json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Build(patch.Env{SampleRate: 44100}); err == nil {
+			t.Errorf("%s: Build succeeded", json)
+		}
+	}
+}
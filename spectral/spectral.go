@@ -0,0 +1,222 @@
+// package spectral runs effects on the DCT of overlapping frames of audio.
+package spectral
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pfcm/fxp"
+	"github.com/pfcm/fxp/dct"
+	"github.com/pfcm/fxp/fix"
+)
+
+// Frame is a frame of DCT coefficients, coefficient k is Coeffs[k] * 2^Exp.
+// Coefficient k is at k / 2n times the sample rate, for a frame of n.
+type Frame struct {
+	Coeffs []fix.S17
+	Exp    int
+	// Controls holds the latest value of each control input, if the
+	// Processor is Controlled.
+	Controls []fix.S17
+}
+
+// Processor changes frames of coefficients.
+type Processor interface {
+	// Process is called with each frame in turn and can change it in
+	// place.
+	Process(f *Frame)
+}
+
+// Controlled is implemented by Processors that take control inputs as well
+// as the audio, see Frame.Controls.
+type Controlled interface {
+	Processor
+	// Controls is the number of control inputs, which come after the
+	// audio.
+	Controls() int
+}
+
+// ProcessorFunc is a func that is a Processor.
+type ProcessorFunc func(f *Frame)
+
+func (p ProcessorFunc) Process(f *Frame) { p(f) }
+
+// Window is the shape frames are faded in and out with.
+type Window int
+
+const (
+	// Hann is a raised cosine, which keeps the frequencies from smearing
+	// into each other.
+	Hann Window = iota
+	// SqrtHann is the square root of Hann, which is Hann once it's been
+	// applied both before and after processing.
+	SqrtHann
+	// Rect doesn't fade at all, so changes to the spectrum click at the
+	// frame edges.
+	Rect
+)
+
+var windowNames = []string{"hann", "sqrthann", "rect"}
+
+func (w Window) String() string {
+	if w < 0 || int(w) >= len(windowNames) {
+		return fmt.Sprintf("Window(%d)", int(w))
+	}
+	return windowNames[w]
+}
+
+// ParseWindow is the opposite of Window.String.
+func ParseWindow(s string) (Window, error) {
+	for i, n := range windowNames {
+		if n == s {
+			return Window(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown window %q, want one of %v", s, windowNames)
+}
+
+// coeffs returns the window for a frame of n.
+func (w Window) coeffs(n int) ([]float64, error) {
+	out := make([]float64, n)
+	for i := range out {
+		hann := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		switch w {
+		case Hann:
+			out[i] = hann
+		case SqrtHann:
+			out[i] = math.Sqrt(hann)
+		case Rect:
+			out[i] = 1
+		default:
+			return nil, fmt.Errorf("unknown window %v", w)
+		}
+	}
+	return out, nil
+}
+
+// windowBits is the number of fractional bits in the windows.
+const windowBits = 14
+
+// Effect is an fxp.Ticker that runs a Processor on the spectrum of its input.
+// It chops the input into frames that overlap, windows them, does a DCT,
+// processes the coefficients, does the inverse, windows again and adds the
+// frames back together. If the Processor is Controlled, the control inputs
+// come after the audio. With a Processor that does nothing the output is the
+// input, Latency samples late.
+type Effect struct {
+	p    Processor
+	d    *dct.DCT
+	hop  int
+	win  Window
+	size int
+	// analysis and synthesis are the windows before and after processing,
+	// synthesis is scaled so the overlapping frames add up to one.
+	analysis, synthesis []int64
+	// in holds the last size samples of input, the last hop of which are
+	// still being filled in.
+	in []fix.S17
+	// pos is how far through the current hop Tick is.
+	pos int
+	// ola holds the overlapping output frames as they're added up, with
+	// 7+windowBits fractional bits, and ready the finished ones being
+	// output.
+	ola   []int64
+	ready []fix.S17
+	frame Frame
+	buf   []fix.S17
+}
+
+var _ fxp.Ticker = &Effect{}
+
+// New returns an Effect with frames of size samples, which needs to be a
+// power of two, every hop samples.
+func New(size, hop int, w Window, p Processor) (*Effect, error) {
+	d, err := dct.New(size)
+	if err != nil {
+		return nil, err
+	}
+	if hop <= 0 || hop > size {
+		return nil, fmt.Errorf("hop %d doesn't fit in frames of %d", hop, size)
+	}
+	win, err := w.coeffs(size)
+	if err != nil {
+		return nil, err
+	}
+	e := &Effect{
+		p:         p,
+		d:         d,
+		hop:       hop,
+		win:       w,
+		size:      size,
+		analysis:  make([]int64, size),
+		synthesis: make([]int64, size),
+		in:        make([]fix.S17, size),
+		ola:       make([]int64, size),
+		ready:     make([]fix.S17, hop),
+		frame:     Frame{Coeffs: make([]fix.S17, size)},
+		buf:       make([]fix.S17, size),
+	}
+	if c, ok := p.(Controlled); ok {
+		e.frame.Controls = make([]fix.S17, c.Controls())
+	}
+	// Each output sample is made of the frames it was in, at positions
+	// hop apart, so those are what need to add up to one.
+	for r := range hop {
+		sum := 0.0
+		for i := r; i < size; i += hop {
+			sum += win[i] * win[i]
+		}
+		if sum < 1e-3 {
+			return nil, fmt.Errorf("%v windows %d apart don't overlap enough", w, hop)
+		}
+		for i := r; i < size; i += hop {
+			e.analysis[i] = int64(math.Round(win[i] * (1 << windowBits)))
+			e.synthesis[i] = int64(math.Round(win[i] / sum * (1 << windowBits)))
+		}
+	}
+	return e, nil
+}
+
+// Latency returns the number of samples the output is behind the input.
+func (e *Effect) Latency() int { return e.size }
+
+func (e *Effect) Inputs() int { return 1 + len(e.frame.Controls) }
+func (*Effect) Outputs() int  { return 1 }
+func (e *Effect) String() string {
+	return fmt.Sprintf("spectral.Effect(%d, %d, %v, %v)", e.size, e.hop, e.win, e.p)
+}
+
+func (e *Effect) Tick(in, out [][]fix.S17) {
+	for i, s := range in[0] {
+		out[0][i] = e.ready[e.pos]
+		e.in[e.size-e.hop+e.pos] = s
+		for j := range e.frame.Controls {
+			e.frame.Controls[j] = in[1+j][i]
+		}
+		e.pos++
+		if e.pos == e.hop {
+			e.process()
+			e.pos = 0
+		}
+	}
+}
+
+// process does a whole frame once in is full.
+func (e *Effect) process() {
+	const half = 1 << (windowBits - 1)
+	for i, s := range e.in {
+		e.buf[i] = fix.S17((int64(s)*e.analysis[i] + half) >> windowBits)
+	}
+	e.frame.Exp = e.d.Forward(e.frame.Coeffs, e.buf)
+	e.p.Process(&e.frame)
+	e.d.Inverse(e.buf, e.frame.Coeffs, e.frame.Exp)
+	for i, s := range e.buf {
+		e.ola[i] += int64(s) * e.synthesis[i]
+	}
+	for i, v := range e.ola[:e.hop] {
+		e.ready[i] = fix.S17(min(max((v+half)>>windowBits, int64(fix.MinS17)), int64(fix.MaxS17)))
+	}
+	copy(e.ola, e.ola[e.hop:])
+	clear(e.ola[e.size-e.hop:])
+	copy(e.in, e.in[e.hop:])
+}
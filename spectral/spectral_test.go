@@ -0,0 +1,128 @@
+package spectral
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// tick runs e over in in awkward sized blocks.
+func tick(e *Effect, in []fix.S17) []fix.S17 {
+	out := make([]fix.S17, len(in))
+	for start, i := 0, 0; start < len(in); i++ {
+		end := min(len(in), start+[]int{1, 37, 200, 5}[i%4])
+		e.Tick([][]fix.S17{in[start:end]}, [][]fix.S17{out[start:end]})
+		start = end
+	}
+	return out
+}
+
+func sine(n int, cycles float64) []fix.S17 {
+	out := make([]fix.S17, n)
+	for i := range out {
+		out[i] = fix.FromFloat(0.8 * math.Sin(2*math.Pi*cycles*float64(i)/float64(n)))
+	}
+	return out
+}
+
+func TestEffectIdentity(t *testing.T) {
+	in := sine(4000, 37)
+	for _, c := range []struct {
+		size, hop int
+		w         Window
+	}{
+		{64, 32, SqrtHann},
+		{64, 16, Hann},
+		{256, 64, Hann},
+		{128, 128, Rect},
+		{128, 32, Rect},
+		{32, 12, SqrtHann},
+	} {
+		e, err := New(c.size, c.hop, c.w, ProcessorFunc(func(*Frame) {}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Latency() != c.size {
+			t.Errorf("%v: Latency() = %d, want: %d", e, e.Latency(), c.size)
+		}
+		got := tick(e, in)
+		// The coefficients only have 8 bits, so there's a little
+		// noise, which spreads through the whole frame.
+		for i := range e.Latency() {
+			if got[i] < -2 || got[i] > 2 {
+				t.Errorf("%v: out[%d] = %v before the latency", e, i, got[i])
+				break
+			}
+		}
+		var sum float64
+		for i := e.Latency(); i < len(in); i++ {
+			d := float64(got[i]) - float64(in[i-e.Latency()])
+			sum += d * d
+			if math.Abs(d) > 8 {
+				t.Errorf("%v: out[%d] = %v, want: %v", e, i, got[i], in[i-e.Latency()])
+				break
+			}
+		}
+		if rms := math.Sqrt(sum / float64(len(in)-e.Latency())); rms > 1.5 {
+			t.Errorf("%v: RMS error %v LSBs", e, rms)
+		}
+	}
+}
+
+func TestEffectProcessor(t *testing.T) {
+	// Silencing every frame silences the output.
+	e, err := New(64, 32, Hann, ProcessorFunc(func(f *Frame) { clear(f.Coeffs) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range tick(e, sine(1000, 10)) {
+		if s != 0 {
+			t.Fatalf("out[%d] = %v, want: 0", i, s)
+		}
+	}
+
+	// Halving every frame halves the output.
+	e, err = New(64, 32, SqrtHann, ProcessorFunc(func(f *Frame) { f.Exp-- }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := sine(1000, 10)
+	got := tick(e, in)
+	for i := e.Latency(); i < len(in); i++ {
+		if want := float64(in[i-e.Latency()]) / 2; math.Abs(float64(got[i])-want) > 3 {
+			t.Errorf("out[%d] = %v, want: %v", i, got[i], want)
+			break
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	nop := ProcessorFunc(func(*Frame) {})
+	for _, c := range []struct {
+		size, hop int
+		w         Window
+	}{
+		{100, 50, Hann},
+		{64, 0, Hann},
+		{64, 65, Hann},
+		// Hann is 0 at the frame edges, so frames need to overlap.
+		{64, 64, Hann},
+		{64, 32, Window(10)},
+	} {
+		if _, err := New(c.size, c.hop, c.w, nop); err == nil {
+			t.Errorf("New(%d, %d, %v) succeeded", c.size, c.hop, c.w)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for _, w := range []Window{Hann, SqrtHann, Rect} {
+		if got, err := ParseWindow(w.String()); err != nil || got != w {
+			t.Errorf("ParseWindow(%q) = %v, %v", w, got, err)
+		}
+	}
+	if _, err := ParseWindow("blackman"); err == nil {
+		t.Error("ParseWindow(blackman) succeeded")
+	}
+}
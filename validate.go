@@ -0,0 +1,84 @@
+package fxp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validator is implemented by Tickers that can check their configuration
+// before they are ticked. Tickers that contain other Tickers should validate
+// those too, using Validate so the errors say where the problem is.
+type Validator interface {
+	Validate() error
+}
+
+// PathError is returned when validation fails, it records where the problem
+// is in a tree of Tickers.
+type PathError struct {
+	// Path describes the Tickers from the outermost one down to the one
+	// with the problem, eg. "Chain[1]", "Concurrent[0]", "Delay(0)".
+	Path []string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", strings.Join(e.Path, " > "), e.Err)
+}
+
+func (e *PathError) Unwrap() error { return e.Err }
+
+// Validate checks t, a Ticker or TickerOf any sample type, and everything it
+// contains. It returns a *PathError describing the first problem found, or
+// nil if everything looks OK. It's a good idea to call it on a whole patch
+// before it gets anywhere near the audio callback.
+func Validate(t any) error {
+	if t == nil {
+		return &PathError{Path: []string{"<nil>"}, Err: errors.New("nil ticker")}
+	}
+	if c, ok := t.(interface {
+		Inputs() int
+		Outputs() int
+	}); ok {
+		if c.Inputs() < 0 || c.Outputs() < 0 {
+			return &PathError{
+				Path: []string{fmt.Sprint(t)},
+				Err:  fmt.Errorf("negative channel count (%d inputs, %d outputs)", c.Inputs(), c.Outputs()),
+			}
+		}
+	}
+	v, ok := t.(Validator)
+	if !ok {
+		return nil
+	}
+	err := v.Validate()
+	if err == nil {
+		return nil
+	}
+	var pe *PathError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &PathError{Path: []string{fmt.Sprint(t)}, Err: err}
+}
+
+// validateChild validates a Ticker inside a container, prefixing any error's
+// path with where it is in the container.
+func validateChild(t any, where string, i int) error {
+	return withPath(Validate(t), fmt.Sprintf("%s[%d]", where, i))
+}
+
+// withPath prefixes the path of err, if it is a *PathError, with elem.
+func withPath(err error, elem string) error {
+	if err == nil {
+		return nil
+	}
+	var pe *PathError
+	if !errors.As(err, &pe) {
+		return &PathError{Path: []string{elem}, Err: err}
+	}
+	return &PathError{
+		Path: append([]string{elem}, pe.Path...),
+		Err:  pe.Err,
+	}
+}
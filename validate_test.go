@@ -0,0 +1,63 @@
+package fxp
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNewChainErrors(t *testing.T) {
+	if _, err := NewChain(); err == nil {
+		t.Error("NewChain() succeeded")
+	}
+	_, err := NewChain(Noop{N: 1}, Mult{N: 2}, Noop{N: 1})
+	var pe *PathError
+	if !errors.As(err, &pe) {
+		t.Fatalf("NewChain with mismatch: got %v, want a *PathError", err)
+	}
+	if want := []string{"Chain[2]"}; !slices.Equal(pe.Path, want) {
+		t.Errorf("Path = %q, want: %q", pe.Path, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := NewGraph(0, 1)
+	g.Add(Noop{N: 1})
+	g.Add(Serially(Noop{N: 2}, Concurrently(Noop{N: 2}, Mixer{})))
+	for _, c := range []struct {
+		name string
+		t    Ticker
+		path []string
+	}{{
+		name: "ok",
+		t:    Serially(Const{Val: 1}, Mult{N: 2}, Concurrently(Noop{N: 1}, Noop{N: 1}), Sum(2)),
+	}, {
+		name: "mixer",
+		t:    Mixer{},
+		path: []string{"Mixer"},
+	}, {
+		name: "negative",
+		t:    Serially(Mult{N: 2}, Concurrently(Noop{N: 1}, Mult{N: -1})),
+		path: []string{"Chain[1]", "Concurrent[1]", "Mult(-1)"},
+	}, {
+		name: "graph",
+		t:    g,
+		path: []string{"Graph[1]", "Chain[1]", "Concurrent[1]", "Mixer"},
+	}} {
+		err := Validate(c.t)
+		if c.path == nil {
+			if err != nil {
+				t.Errorf("%s: Validate: %v", c.name, err)
+			}
+			continue
+		}
+		var pe *PathError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: Validate: got %v, want a *PathError", c.name, err)
+			continue
+		}
+		if !slices.Equal(pe.Path, c.path) {
+			t.Errorf("%s: Path = %q, want: %q", c.name, pe.Path, c.path)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// package vec does saturating arithmetic on whole blocks of fix.S17s. The
+// loops are written to avoid branches and bounds checks, so they're a good
+// deal faster than calling the fix methods one sample at a time.
+//
+// In all of these, dst may be the same slice as any of the sources. The
+// sources must be at least as long as dst.
+package vec
+
+import "github.com/pfcm/fxp/fix"
+
+// sat clamps a wide value into an S17. min and max compile to conditional
+// moves, not branches, and the check for counting saturations compiles away
+// unless it's turned on.
+func sat(v int32) fix.S17 {
+	if fix.Counting && (v < int32(fix.MinS17) || v > int32(fix.MaxS17)) {
+		fix.Saturated()
+	}
+	return fix.S17(min(max(v, int32(fix.MinS17)), int32(fix.MaxS17)))
+}
+
+// Add sets dst to a + b.
+func Add(dst, a, b []fix.S17) {
+	a, b = a[:len(dst)], b[:len(dst)]
+	for i := range dst {
+		dst[i] = sat(int32(a[i]) + int32(b[i]))
+	}
+}
+
+// Mul sets dst to a * b, rounding down like fix.S17.SMul.
+func Mul(dst, a, b []fix.S17) {
+	a, b = a[:len(dst)], b[:len(dst)]
+	for i := range dst {
+		dst[i] = sat(int32(a[i]) * int32(b[i]) >> 7)
+	}
+}
+
+// CopyGain sets dst to src * gain.
+func CopyGain(dst, src []fix.S17, gain fix.S17) {
+	src = src[:len(dst)]
+	g := int32(gain)
+	for i := range dst {
+		dst[i] = sat(int32(src[i]) * g >> 7)
+	}
+}
+
+// ScaleOffset sets dst to src * mul + offset, only saturating once at the
+// end.
+func ScaleOffset(dst, src []fix.S17, mul, offset fix.S17) {
+	src = src[:len(dst)]
+	m, o := int32(mul), int32(offset)
+	for i := range dst {
+		dst[i] = sat(int32(src[i])*m>>7 + o)
+	}
+}
+
+// MixGain adds src * gain to dst, only saturating the sum. Mixing several
+// signals this way clips after each one, to avoid that use MAC.
+func MixGain(dst, src []fix.S17, gain fix.S17) {
+	src = src[:len(dst)]
+	g := int32(gain)
+	for i := range dst {
+		dst[i] = sat(int32(dst[i]) + int32(src[i])*g>>7)
+	}
+}
+
+// MAC adds src * gain to acc exactly. It doesn't saturate, but it would take
+// over 100,000 full scale products to overflow.
+func MAC(acc []fix.Acc32, src []fix.S17, gain fix.S17) {
+	src = src[:len(acc)]
+	g := int32(gain)
+	for i := range acc {
+		acc[i] += fix.Acc32(int32(src[i]) * g)
+	}
+}
+
+// Narrow sets dst to acc narrowed to S17s, like fix.Acc32.S17.
+func Narrow(dst []fix.S17, acc []fix.Acc32) {
+	acc = acc[:len(dst)]
+	for i := range dst {
+		dst[i] = sat(int32(acc[i] >> 7))
+	}
+}
+
+// Clip sets dst to src clamped between lo and hi.
+func Clip(dst, src []fix.S17, lo, hi fix.S17) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] = min(max(src[i], lo), hi)
+	}
+}
@@ -0,0 +1,154 @@
+package vec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pfcm/fxp/fix"
+)
+
+// all returns every pair of S17s as two slices.
+func all() (a, b []fix.S17) {
+	for i := int(fix.MinS17); i <= int(fix.MaxS17); i++ {
+		for j := int(fix.MinS17); j <= int(fix.MaxS17); j++ {
+			a = append(a, fix.S17(i))
+			b = append(b, fix.S17(j))
+		}
+	}
+	return a, b
+}
+
+func TestBinary(t *testing.T) {
+	a, b := all()
+	for _, c := range []struct {
+		name string
+		op   func(dst, a, b []fix.S17)
+		want func(a, b fix.S17) fix.S17
+	}{
+		{"Add", Add, fix.S17.SAdd},
+		{"Mul", Mul, fix.S17.SMul},
+		{"CopyGain", func(dst, a, b []fix.S17) {
+			// One gain at a time.
+			for i := 0; i < len(dst); i += 256 {
+				CopyGain(dst[i:i+256], b[i:i+256], a[i])
+			}
+		}, fix.S17.SMul},
+		{"MixGain", func(dst, a, b []fix.S17) {
+			for i := 0; i < len(dst); i += 256 {
+				copy(dst[i:i+256], b[i:i+256])
+				MixGain(dst[i:i+256], b[i:i+256], a[i])
+			}
+		}, func(a, b fix.S17) fix.S17 {
+			return fix.S17(min(max(int(b)+int(a)*int(b)>>7, -128), 127))
+		}},
+		{"Clip", func(dst, a, b []fix.S17) {
+			for i := 0; i < len(dst); i += 256 {
+				lo := min(a[i], 0)
+				Clip(dst[i:i+256], b[i:i+256], lo, -lo-1)
+			}
+		}, func(a, b fix.S17) fix.S17 { lo := min(a, 0); return min(max(b, lo), -lo-1) }},
+	} {
+		dst := make([]fix.S17, len(a))
+		c.op(dst, a, b)
+		for i := range dst {
+			if want := c.want(a[i], b[i]); dst[i] != want {
+				t.Fatalf("%s(%v, %v) = %v, want: %v", c.name, a[i], b[i], dst[i], want)
+			}
+		}
+	}
+}
+
+func TestScaleOffset(t *testing.T) {
+	a, b := all()
+	dst := make([]fix.S17, 256)
+	for i := 0; i < len(a); i += 256 {
+		for _, off := range []fix.S17{0, 3, -100, fix.MaxS17} {
+			ScaleOffset(dst, b[i:i+256], a[i], off)
+			for j, got := range dst {
+				exact := int(a[i])*int(b[i+j])>>7 + int(off)
+				want := fix.S17(min(max(exact, -128), 127))
+				if got != want {
+					t.Fatalf("ScaleOffset(%v, %v, %v) = %v, want: %v", b[i+j], a[i], off, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMACNarrow(t *testing.T) {
+	a, b := all()
+	acc := make([]fix.Acc32, 256)
+	dst := make([]fix.S17, 256)
+	for i := 0; i < len(a); i += 256 {
+		clear(acc)
+		MAC(acc, b[i:i+256], a[i])
+		MAC(acc, b[i:i+256], -a[i]/2)
+		Narrow(dst, acc)
+		for j, got := range dst {
+			want := fix.Acc32(0).MAC(b[i+j], a[i]).MAC(b[i+j], -a[i]/2).S17()
+			if got != want {
+				t.Fatalf("%v * %v - %v * %v = %v, want: %v", b[i+j], a[i], b[i+j], a[i]/2, got, want)
+			}
+		}
+	}
+}
+
+var sizes = []int{64, 512, 4096}
+
+func bench(b *testing.B, name string, f func(dst, a, b []fix.S17)) {
+	for _, n := range sizes {
+		x, y, dst := make([]fix.S17, n), make([]fix.S17, n), make([]fix.S17, n)
+		for i := range x {
+			x[i], y[i] = fix.S17(i*7), fix.S17(i*13)
+		}
+		b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+			b.SetBytes(int64(n))
+			for range b.N {
+				f(dst, x, y)
+			}
+		})
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	bench(b, "vec", Add)
+	bench(b, "scalar", func(dst, x, y []fix.S17) {
+		for i := range dst {
+			dst[i] = x[i].SAdd(y[i])
+		}
+	})
+}
+
+func BenchmarkMul(b *testing.B) {
+	bench(b, "vec", Mul)
+	bench(b, "scalar", func(dst, x, y []fix.S17) {
+		for i := range dst {
+			dst[i] = x[i].SMul(y[i])
+		}
+	})
+}
+
+func BenchmarkScaleOffset(b *testing.B) {
+	bench(b, "vec", func(dst, x, _ []fix.S17) { ScaleOffset(dst, x, 100, 3) })
+	bench(b, "scalar", func(dst, x, _ []fix.S17) {
+		for i := range dst {
+			dst[i] = x[i].SMul(100).SAdd(3)
+		}
+	})
+}
+
+func BenchmarkMix(b *testing.B) {
+	acc := make([]fix.Acc32, 4096)
+	bench(b, "vec", func(dst, x, y []fix.S17) {
+		a := acc[:len(dst)]
+		clear(a)
+		MAC(a, x, 100)
+		MAC(a, y, 50)
+		Narrow(dst, a)
+	})
+	bench(b, "scalar", func(dst, x, y []fix.S17) {
+		for i := range dst {
+			dst[i] = fix.Acc32(0).MAC(x[i], 100).MAC(y[i], 50).S17()
+		}
+	})
+}